@@ -12,7 +12,9 @@ import (
 // Config is the data structure that represents the root of the configuration.
 type Config struct {
 	ColorVariant string `yaml:"colorVariant"`
-	Modules      []any  `yaml:"modules"`
+	// StickyWidth is the default for each module's sticky_width option.
+	StickyWidth bool  `yaml:"stickyWidth"`
+	Modules     []any `yaml:"modules"`
 }
 
 var defaultConfig = Config{
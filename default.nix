@@ -3,7 +3,7 @@ buildGoModule {
   pname = "gobar";
   version = "0.1.9";
   src = ./.;
-  vendorHash = "sha256-sBuOR6VxCyqkvuVU7SZOBIUkoQek7WjuXAx1++JxKyU=";
+  vendorHash = "sha256-PAIhwHd4NQVpHoZsoUtmp1ZnlO+LVcywL4aw4N1i1Dg=";
   ldflags = [
     "-s"
     "-w"
@@ -4,14 +4,16 @@ go 1.19
 
 require (
 	github.com/go-yaml/yaml v2.1.0+incompatible
+	github.com/itchyny/gojq v0.12.13
 	github.com/mitchellh/mapstructure v1.5.0
 	github.com/vishvananda/netlink v1.1.0
+	github.com/vishvananda/netns v0.0.4
 	golang.org/x/exp v0.0.0-20240604190554-fc45aab8b7f8
 	golang.org/x/sys v0.21.0
 )
 
 require (
-	github.com/vishvananda/netns v0.0.4 // indirect
+	github.com/itchyny/timefmt-go v0.1.5 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -0,0 +1,161 @@
+package i3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modifiers is a set of keyboard modifiers held during a click.
+type Modifiers uint8
+
+const (
+	// Shift represents the shift key.
+	Shift Modifiers = 1 << iota
+	// Control represents the control key.
+	Control
+	// Mod1 usually represents the alt key.
+	Mod1
+	// Mod2 usually represents Num Lock.
+	Mod2
+	// Mod3 is usually unused.
+	Mod3
+	// Mod4 usually represents the super key.
+	Mod4
+	// Mod5 usually represents the AltGr key.
+	Mod5
+	// Lock represents Caps Lock.
+	Lock
+)
+
+// IgnoredModifiers are the lock modifiers that are ignored when matching a
+// click against a Binding, so that bindings still match while Caps Lock or
+// Num Lock are on.
+const IgnoredModifiers = Lock | Mod2
+
+var modifierNames = map[string]Modifiers{
+	"shift":   Shift,
+	"control": Control,
+	"mod1":    Mod1,
+	"mod2":    Mod2,
+	"mod3":    Mod3,
+	"mod4":    Mod4,
+	"mod5":    Mod5,
+	"lock":    Lock,
+}
+
+// modifierAliases are the friendlier names accepted by ParseBinding.
+var modifierAliases = map[string]Modifiers{
+	"ctrl":  Control,
+	"alt":   Mod1,
+	"super": Mod4,
+}
+
+var buttonNames = map[string]int{
+	"left":         LeftClick,
+	"middle":       MiddleClick,
+	"right":        RightClick,
+	"scroll_up":    ScrollUp,
+	"scroll_down":  ScrollDown,
+	"scroll_left":  ScrollLeft,
+	"scroll_right": ScrollRight,
+	"back":         BackClick,
+	"forward":      ForwardClick,
+}
+
+// ParseModifiers returns the set of modifiers named in a click event, such as
+// "Shift" or "Mod1". Unknown names are ignored.
+func ParseModifiers(names []string) Modifiers {
+	var m Modifiers
+	for _, name := range names {
+		m |= modifierNames[strings.ToLower(name)]
+	}
+	return m
+}
+
+// Has returns whether all modifiers in other are in the set.
+func (m Modifiers) Has(other Modifiers) bool {
+	return m&other == other
+}
+
+// ModifierSet returns the modifiers held during the click.
+func (e ClickEvent) ModifierSet() Modifiers {
+	return ParseModifiers(e.Modifiers)
+}
+
+// IsScroll returns whether the click was a scroll of the mouse wheel.
+func (e ClickEvent) IsScroll() bool {
+	return e.Button >= ScrollUp && e.Button <= ScrollRight
+}
+
+// Binding is a mouse button pressed while holding a set of modifiers.
+type Binding struct {
+	Button    int
+	Modifiers Modifiers
+}
+
+// ParseBinding parses a button name optionally prefixed with modifiers, such
+// as "left", "shift+right", "ctrl+alt+middle" or "scroll_up".
+func ParseBinding(s string) (Binding, error) {
+	parts := strings.Split(strings.ToLower(s), "+")
+	button, ok := buttonNames[parts[len(parts)-1]]
+	if !ok {
+		return Binding{}, fmt.Errorf("invalid button in binding %q", s)
+	}
+
+	b := Binding{Button: button}
+	for _, part := range parts[:len(parts)-1] {
+		modifier, ok := modifierNames[part]
+		if !ok {
+			modifier, ok = modifierAliases[part]
+		}
+		if !ok {
+			return Binding{}, fmt.Errorf("invalid modifier in binding %q", s)
+		}
+		b.Modifiers |= modifier
+	}
+
+	return b, nil
+}
+
+// Matches returns whether the click was made with the binding's button and
+// exactly the binding's modifiers, not counting IgnoredModifiers.
+func (b Binding) Matches(e ClickEvent) bool {
+	ignored := IgnoredModifiers &^ b.Modifiers
+	return e.Button == b.Button && e.ModifierSet()&^ignored == b.Modifiers
+}
+
+type handler struct {
+	binding Binding
+	action  func(ClickEvent)
+}
+
+// Dispatcher maps clicks to actions by their button and modifiers.
+type Dispatcher struct {
+	handlers []handler
+}
+
+// Handle registers an action to run when a click matches the binding.
+func (d *Dispatcher) Handle(b Binding, action func(ClickEvent)) {
+	d.handlers = append(d.handlers, handler{binding: b, action: action})
+}
+
+// HandleButtons registers an action to run when a click without modifiers is
+// made with any of the buttons.
+func (d *Dispatcher) HandleButtons(action func(ClickEvent), buttons ...int) {
+	for _, button := range buttons {
+		d.Handle(Binding{Button: button}, action)
+	}
+}
+
+// Dispatch runs every action whose binding matches the click and returns
+// whether any did.
+func (d *Dispatcher) Dispatch(e ClickEvent) bool {
+	matched := false
+	for _, h := range d.handlers {
+		if h.binding.Matches(e) {
+			matched = true
+			h.action(e)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,101 @@
+package i3
+
+import "testing"
+
+func TestBindingMatches(t *testing.T) {
+	tt := []struct {
+		name    string
+		binding string
+		click   ClickEvent
+		want    bool
+	}{
+		{
+			name:    "plain left click",
+			binding: "left",
+			click:   ClickEvent{Button: LeftClick},
+			want:    true,
+		},
+		{
+			name:    "different button",
+			binding: "left",
+			click:   ClickEvent{Button: RightClick},
+			want:    false,
+		},
+		{
+			name:    "extra modifier",
+			binding: "left",
+			click:   ClickEvent{Button: LeftClick, Modifiers: []string{"Shift"}},
+			want:    false,
+		},
+		{
+			name:    "modifier",
+			binding: "shift+right",
+			click:   ClickEvent{Button: RightClick, Modifiers: []string{"Shift"}},
+			want:    true,
+		},
+		{
+			name:    "aliases",
+			binding: "Ctrl+Alt+scroll_up",
+			click:   ClickEvent{Button: ScrollUp, Modifiers: []string{"Mod1", "Control"}},
+			want:    true,
+		},
+		{
+			name:    "num lock and caps lock are ignored",
+			binding: "scroll_down",
+			click:   ClickEvent{Button: ScrollDown, Modifiers: []string{"Mod2", "Lock"}},
+			want:    true,
+		},
+		{
+			name:    "explicit lock modifier",
+			binding: "lock+back",
+			click:   ClickEvent{Button: BackClick},
+			want:    false,
+		},
+	}
+
+	for _, tc := range tt {
+		binding, err := ParseBinding(tc.binding)
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		got := binding.Matches(tc.click)
+		if got != tc.want {
+			t.Fatalf("%s: got %t, wanted %t\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseBindingInvalid(t *testing.T) {
+	for _, s := range []string{"", "top", "hyper+left", "left+right", "shift+", "+left", "shift++left", "ctrl-left", "left+shift"} {
+		if _, err := ParseBinding(s); err == nil {
+			t.Fatalf("%q: expected error\n", s)
+		}
+	}
+}
+
+func TestParseBinding(t *testing.T) {
+	tt := []struct {
+		binding string
+		want    Binding
+	}{
+		{binding: "left", want: Binding{Button: LeftClick}},
+		{binding: "scroll_down", want: Binding{Button: ScrollDown}},
+		{binding: "Forward", want: Binding{Button: ForwardClick}},
+		{binding: "shift+right", want: Binding{Button: RightClick, Modifiers: Shift}},
+		{binding: "ctrl+alt+middle", want: Binding{Button: MiddleClick, Modifiers: Control | Mod1}},
+		{binding: "control+mod1+middle", want: Binding{Button: MiddleClick, Modifiers: Control | Mod1}},
+		{binding: "SUPER+Shift+scroll_left", want: Binding{Button: ScrollLeft, Modifiers: Mod4 | Shift}},
+		{binding: "shift+shift+back", want: Binding{Button: BackClick, Modifiers: Shift}},
+		{binding: "mod2+lock+mod3+mod5+left", want: Binding{Button: LeftClick, Modifiers: Mod2 | Lock | Mod3 | Mod5}},
+	}
+
+	for _, tc := range tt {
+		got, err := ParseBinding(tc.binding)
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.binding, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.binding, got, tc.want)
+		}
+	}
+}
@@ -2,15 +2,30 @@
 // protocol.
 package i3
 
-import "syscall"
+import (
+	"encoding/json"
+	"syscall"
+)
 
 const (
 	// LeftClick represents a left mouse click.
 	LeftClick = iota + 1
-	// MiddleClick represents a left mouse click.
+	// MiddleClick represents a middle mouse click.
 	MiddleClick
-	// RightClick represents a left mouse click.
+	// RightClick represents a right mouse click.
 	RightClick
+	// ScrollUp represents scrolling the mouse wheel up.
+	ScrollUp
+	// ScrollDown represents scrolling the mouse wheel down.
+	ScrollDown
+	// ScrollLeft represents scrolling the mouse wheel left.
+	ScrollLeft
+	// ScrollRight represents scrolling the mouse wheel right.
+	ScrollRight
+	// BackClick represents a click of the mouse's back button.
+	BackClick
+	// ForwardClick represents a click of the mouse's forward button.
+	ForwardClick
 )
 
 // Header is the first thing that i3bar will read to determine how this program
@@ -34,6 +49,7 @@ type Block struct {
 	BorderBottom        int    `json:"border_bottom,omitempty"`
 	BorderLeft          int    `json:"border_left,omitempty"`
 	MinWidth            int    `json:"min_width,omitempty"`
+	MinWidthText        string `json:"-"`
 	Align               string `json:"align,omitempty"`
 	Urgent              bool   `json:"urgent,omitempty"`
 	Name                string `json:"name,omitempty"`
@@ -43,6 +59,43 @@ type Block struct {
 	Markup              string `json:"markup,omitempty"`
 }
 
+// MarshalJSON implements json.Marshaler. If MinWidthText is set, min_width is
+// sent in its string form, which i3bar uses to size the block as wide as the
+// rendered text; otherwise MinWidth is sent as a number of pixels.
+func (b Block) MarshalJSON() ([]byte, error) {
+	type block Block
+	aux := struct {
+		block
+		MinWidth any `json:"min_width,omitempty"`
+	}{block: block(b)}
+	if b.MinWidthText != "" {
+		aux.MinWidth = b.MinWidthText
+	} else if b.MinWidth != 0 {
+		aux.MinWidth = b.MinWidth
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both the number and
+// string forms of min_width.
+func (b *Block) UnmarshalJSON(data []byte) error {
+	type block Block
+	aux := struct {
+		*block
+		MinWidth any `json:"min_width,omitempty"`
+	}{block: (*block)(b)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	switch minWidth := aux.MinWidth.(type) {
+	case float64:
+		b.MinWidth = int(minWidth)
+	case string:
+		b.MinWidthText = minWidth
+	}
+	return nil
+}
+
 // ClickEvent is the data sent to this program via STDIN when a click is
 // registered on the i3bar.
 type ClickEvent struct {
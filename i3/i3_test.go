@@ -0,0 +1,104 @@
+package i3
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBlockJSON(t *testing.T) {
+	tt := []struct {
+		name  string
+		block Block
+		want  string
+	}{
+		{
+			name:  "no min width",
+			block: Block{FullText: "CPU: 5%"},
+			want:  `{"full_text":"CPU: 5%"}`,
+		},
+		{
+			name:  "pixels",
+			block: Block{FullText: "CPU: 5%", MinWidth: 120},
+			want:  `{"full_text":"CPU: 5%","min_width":120}`,
+		},
+		{
+			name:  "text",
+			block: Block{FullText: "CPU: 5%", MinWidthText: "CPU: 100%"},
+			want:  `{"full_text":"CPU: 5%","min_width":"CPU: 100%"}`,
+		},
+		{
+			name:  "text takes precedence",
+			block: Block{FullText: "CPU: 5%", MinWidth: 120, MinWidthText: "CPU: 100%"},
+			want:  `{"full_text":"CPU: 5%","min_width":"CPU: 100%"}`,
+		},
+		{
+			name:  "other fields",
+			block: Block{FullText: "vol", Name: "command", Instance: "vol", Urgent: true, Markup: "pango"},
+			want:  `{"full_text":"vol","urgent":true,"name":"command","instance":"vol","markup":"pango"}`,
+		},
+	}
+
+	for _, tc := range tt {
+		data, err := json.Marshal(tc.block)
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if string(data) != tc.want {
+			t.Fatalf("%s: got %s, wanted %s\n", tc.name, data, tc.want)
+		}
+
+		// Only one form of min_width is sent, so it is the one read back.
+		want := tc.block
+		if want.MinWidthText != "" {
+			want.MinWidth = 0
+		}
+		var got Block
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got, want)
+		}
+	}
+}
+
+func TestBlockUnmarshalJSON(t *testing.T) {
+	tt := []struct {
+		name    string
+		data    string
+		want    Block
+		wantErr bool
+	}{
+		{
+			name: "pixels",
+			data: `{"full_text": "vol", "min_width": 100}`,
+			want: Block{FullText: "vol", MinWidth: 100},
+		},
+		{
+			name: "text",
+			data: `{"full_text": "vol", "min_width": "vol 100%"}`,
+			want: Block{FullText: "vol", MinWidthText: "vol 100%"},
+		},
+		{
+			name: "no min width",
+			data: `{"full_text": "vol", "color": "#ff0000"}`,
+			want: Block{FullText: "vol", Color: "#ff0000"},
+		},
+		{
+			name:    "invalid",
+			data:    `{"full_text": 42}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		var got Block
+		err := json.Unmarshal([]byte(tc.data), &got)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, wanted error %t\n", tc.name, err, tc.wantErr)
+		}
+		if err == nil && got != tc.want {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got, tc.want)
+		}
+	}
+}
@@ -7,23 +7,132 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strconv"
 	"time"
 
 	col "github.com/jmbaur/gobar/color"
 	"github.com/jmbaur/gobar/i3"
 )
 
-// Battery is a module that prints the capacity of batteries. Only works on
-// Linux.
+// defaultBatteryIcons are shown before the capacity for each battery status
+// reported by the kernel.
+var defaultBatteryIcons = map[string]string{
+	"Charging":     "▲",
+	"Discharging":  "▼",
+	"Full":         "●",
+	"Not charging": "■",
+	"Unknown":      "?",
+}
+
+// Battery is a module that prints the capacity, charging status, time until
+// empty or full and power draw of batteries, and optionally whether AC
+// adapters are plugged in. Batteries and adapters are read as soon as the
+// kernel reports a change. Only works on Linux.
 type Battery struct {
+	// Icons shown for each charging status, "Charging", "Discharging",
+	// "Full", "Not charging" and "Unknown". Defaults to triangles for
+	// charging and discharging, a circle when full and a square when not
+	// charging.
+	Icons map[string]string `mapstructure:"icons"`
+	// Capacity percentages below which a discharging battery is colored
+	// yellow and red. Default to 20 and 10.
+	Warning  int `mapstructure:"warning"`
+	Critical int `mapstructure:"critical"`
+	// Whether to show the AC adapters, including USB power supplies.
+	Adapters bool `mapstructure:"adapters"`
+	// How often to read the batteries, in case the kernel doesn't report a
+	// change such as the capacity dropping. Defaults to 30 seconds, or 5
+	// seconds if changes can't be received from the kernel.
+	Interval time.Duration `mapstructure:"interval"`
+
+	root      string
+	supplies  []powerSupply
 	batteries []batteryInfo
+	adapters  []adapterInfo
+}
+
+// powerSupply is a battery or adapter found under the power_supply
+// directory.
+type powerSupply struct {
+	name    string
+	adapter bool
+}
+
+// adapterInfo is the state of a power supply that isn't a battery, such as
+// an AC adapter.
+type adapterInfo struct {
+	name   string
+	online bool
 }
 
 type batteryInfo struct {
-	fd       *os.File
-	capacity int
 	name     string
+	capacity int
+	// the charging status, for example "Charging" or "Discharging"
+	status string
+	// power draw in watts, zero if the battery doesn't report it
+	power float64
+	// estimated time until the battery is empty when discharging or full
+	// when charging, zero if unknown
+	remaining time.Duration
+}
+
+// readBattery reads the state of the battery in the power_supply directory
+// dir. Batteries report their energy in µWh and power in µW, or their charge
+// in µAh and current in µA.
+func readBattery(dir string) (batteryInfo, error) {
+	bat := batteryInfo{
+		name:   filepath.Base(dir),
+		status: readSysfsString(filepath.Join(dir, "status")),
+	}
+	if bat.status == "" {
+		bat.status = "Unknown"
+	}
+
+	capacity, err := readSysfsFloat(filepath.Join(dir, "capacity"))
+	if err != nil {
+		return bat, err
+	}
+	bat.capacity = int(capacity)
+
+	read := func(name string) float64 {
+		v, err := readSysfsFloat(filepath.Join(dir, name))
+		if err != nil || v < 0 {
+			return 0
+		}
+		return v
+	}
+
+	// Some drivers report a negative current while discharging.
+	rate, now, full := read("power_now"), read("energy_now"), read("energy_full")
+	bat.power = rate / 1e6
+	if rate == 0 {
+		current, err := readSysfsFloat(filepath.Join(dir, "current_now"))
+		if err == nil && current < 0 {
+			current = -current
+		}
+		rate, now, full = current, read("charge_now"), read("charge_full")
+		bat.power = current * read("voltage_now") / 1e12
+	}
+
+	if rate > 0 {
+		switch bat.status {
+		case "Discharging":
+			bat.remaining = time.Duration(now / rate * float64(time.Hour))
+		case "Charging":
+			if full > now {
+				bat.remaining = time.Duration((full - now) / rate * float64(time.Hour))
+			}
+		}
+	}
+
+	return bat, nil
+}
+
+// formatRemaining formats the time until a battery is empty or full, for
+// example "1h05m".
+func formatRemaining(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
 }
 
 func (b *Battery) print(tx chan []i3.Block, err error, c col.Color) {
@@ -40,37 +149,81 @@ func (b *Battery) print(tx chan []i3.Block, err error, c col.Color) {
 	blocks := []i3.Block{}
 	for _, bat := range b.batteries {
 		color := c.Normal()
+		urgent := false
 
-		if bat.capacity < 10 {
-			color = c.Red()
-		} else if bat.capacity < 20 {
+		switch bat.status {
+		case "Charging":
+			color = c.Green()
+		case "Not charging":
 			color = c.Yellow()
+		case "Full":
+		default:
+			if bat.capacity < b.Critical {
+				color = c.Red()
+				urgent = bat.capacity < b.Critical/2
+			} else if bat.capacity < b.Warning {
+				color = c.Yellow()
+			}
 		}
 
-		text := fmt.Sprintf("%s: %d%%", bat.name, bat.capacity)
+		icon, ok := b.Icons[bat.status]
+		if !ok {
+			icon = defaultBatteryIcons[bat.status]
+		}
+
+		short := fmt.Sprintf("%s: %d%%", bat.name, bat.capacity)
+		if icon != "" {
+			short = fmt.Sprintf("%s: %s %d%%", bat.name, icon, bat.capacity)
+		}
+		text := short
+		if bat.remaining > 0 {
+			text += " " + formatRemaining(bat.remaining)
+		}
+		if bat.power > 0 {
+			text += fmt.Sprintf(" %.1fW", bat.power)
+		}
 
+		blocks = append(blocks, i3.Block{
+			Name:         "battery",
+			Instance:     bat.name,
+			FullText:     text,
+			Color:        color,
+			ShortText:    short,
+			MinWidthText: text,
+			Urgent:       urgent,
+		})
+	}
+
+	for _, adapter := range b.adapters {
+		text := fmt.Sprintf("%s: off", adapter.name)
+		color := c.Normal()
+		if adapter.online {
+			text = fmt.Sprintf("%s: on", adapter.name)
+			color = c.Green()
+		}
 		blocks = append(blocks, i3.Block{
 			Name:      "battery",
-			Instance:  bat.name,
+			Instance:  adapter.name,
 			FullText:  text,
-			Color:     color,
 			ShortText: text,
-			MinWidth:  len(text),
-			Urgent:    bat.capacity < 5,
+			Color:     color,
 		})
 	}
+
 	tx <- blocks
 }
 
-// Run implements Module.
-func (b *Battery) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
-	if err := filepath.WalkDir("/sys/class/power_supply", func(path string, _ fs.DirEntry, err error) error {
+// discover finds the batteries, and the AC adapters if they are shown, under
+// the power_supply directory.
+func (b *Battery) discover() error {
+	b.supplies = b.supplies[:0]
+	return filepath.WalkDir(b.root, func(path string, _ fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		base := filepath.Base(path)
-		if base == "power_supply" {
+		if path == b.root {
 			return nil
 		}
 
@@ -85,40 +238,81 @@ func (b *Battery) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 			return err
 		}
 
-		// don't include a power_supply that is not classified as a battery
-		if string(bytes.TrimSpace(typeContents)) != "Battery" {
+		supply := powerSupply{name: base}
+		switch string(bytes.TrimSpace(typeContents)) {
+		case "Battery":
+			// don't include a battery that doesn't have the capacity file
+			if _, err := os.Stat(filepath.Join(path, "capacity")); err != nil {
+				return nil
+			}
+		case "Mains", "USB":
+			// don't include an adapter that doesn't have the online file
+			if _, err := os.Stat(filepath.Join(path, "online")); err != nil || !b.Adapters {
+				return nil
+			}
+			supply.adapter = true
+		default:
 			return nil
 		}
 
-		// don't include a battery that doesn't have the capacity file
-		if _, err := os.Stat(filepath.Join(path, "capacity")); err != nil {
-			return nil
+		b.supplies = append(b.supplies, supply)
+
+		return nil
+	})
+}
+
+// update reads the state of the batteries and adapters.
+func (b *Battery) update() {
+	b.batteries = b.batteries[:0]
+	b.adapters = b.adapters[:0]
+	for _, supply := range b.supplies {
+		dir := filepath.Join(b.root, supply.name)
+		if supply.adapter {
+			b.adapters = append(b.adapters, adapterInfo{
+				name:   supply.name,
+				online: readSysfsString(filepath.Join(dir, "online")) == "1",
+			})
+			continue
 		}
 
-		b.batteries = append(b.batteries, batteryInfo{name: base})
+		bat, err := readBattery(dir)
+		if err != nil {
+			continue
+		}
+		b.batteries = append(b.batteries, bat)
+	}
+}
 
-		return nil
-	}); err != nil {
+// Run implements Module.
+func (b *Battery) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if b.root == "" {
+		b.root = "/sys/class/power_supply"
+	}
+	if b.Warning == 0 {
+		b.Warning = 20
+	}
+	if b.Critical == 0 {
+		b.Critical = 10
+	}
+
+	if err := b.discover(); err != nil {
 		b.print(tx, err, c)
 		return
 	}
 
-	for i, bat := range b.batteries {
-		fd, err := os.Open(fmt.Sprintf("/sys/class/power_supply/%s/capacity", bat.name))
+	done := make(chan struct{})
+	events, err := subscribeUevents("power_supply", done)
+	if b.Interval <= 0 {
+		b.Interval = 30 * time.Second
 		if err != nil {
-			b.print(tx, err, c)
-			return
+			b.Interval = 5 * time.Second
 		}
-		b.batteries[i].fd = fd
 	}
 
 	ready := make(chan struct{}, 1)
-
 	defer func() {
 		close(ready)
-		for _, bat := range b.batteries {
-			bat.fd.Close()
-		}
+		close(done)
 	}()
 
 	go func() {
@@ -129,25 +323,28 @@ func (b *Battery) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 		select {
 		// no click support for battery
 		case <-rx:
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			// Batteries and adapters can come and go, for example when
+			// docking, and events may have been lost.
+			if ev.action != "change" {
+				if err := b.discover(); err != nil {
+					b.print(tx, err, c)
+					continue
+				}
+			}
+			b.update()
+			b.print(tx, nil, c)
 		case <-ready:
 			{
-				for i, bat := range b.batteries {
-					bat.fd.Seek(0, io.SeekStart)
-					data, err := io.ReadAll(bat.fd)
-					if err != nil {
-						b.print(tx, err, c)
-					}
-					capacity, err := strconv.Atoi(string(bytes.TrimSpace(data)))
-					if err != nil {
-						continue
-					}
-					b.batteries[i].capacity = capacity
-				}
-
+				b.update()
 				b.print(tx, nil, c)
 
 				go func() {
-					time.Sleep(5 * time.Second)
+					time.Sleep(b.Interval)
 					ready <- struct{}{}
 				}()
 			}
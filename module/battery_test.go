@@ -0,0 +1,167 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadBattery(t *testing.T) {
+	tt := []struct {
+		name          string
+		files         map[string]string
+		wantStatus    string
+		wantPower     float64
+		wantRemaining time.Duration
+	}{
+		{
+			name: "discharging with energy",
+			files: map[string]string{
+				"capacity":    "50",
+				"status":      "Discharging",
+				"power_now":   "10000000",
+				"energy_now":  "25000000",
+				"energy_full": "50000000",
+			},
+			wantStatus:    "Discharging",
+			wantPower:     10,
+			wantRemaining: 150 * time.Minute,
+		},
+		{
+			name: "charging with energy",
+			files: map[string]string{
+				"capacity":    "50",
+				"status":      "Charging",
+				"power_now":   "20000000",
+				"energy_now":  "25000000",
+				"energy_full": "50000000",
+			},
+			wantStatus:    "Charging",
+			wantPower:     20,
+			wantRemaining: 75 * time.Minute,
+		},
+		{
+			name: "discharging with charge",
+			files: map[string]string{
+				"capacity":    "40",
+				"status":      "Discharging",
+				"current_now": "-2000000",
+				"voltage_now": "12000000",
+				"charge_now":  "3000000",
+				"charge_full": "7500000",
+			},
+			wantStatus:    "Discharging",
+			wantPower:     24,
+			wantRemaining: 90 * time.Minute,
+		},
+		{
+			name: "full",
+			files: map[string]string{
+				"capacity":    "100",
+				"status":      "Full",
+				"power_now":   "0",
+				"energy_now":  "50000000",
+				"energy_full": "50000000",
+			},
+			wantStatus: "Full",
+		},
+		{
+			name: "capacity only",
+			files: map[string]string{
+				"capacity": "80",
+			},
+			wantStatus: "Unknown",
+		},
+	}
+
+	for _, tc := range tt {
+		dir := filepath.Join(t.TempDir(), "BAT0")
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for name, contents := range tc.files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(contents+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		bat, err := readBattery(dir)
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if bat.status != tc.wantStatus {
+			t.Fatalf("%s: got status %q, wanted %q\n", tc.name, bat.status, tc.wantStatus)
+		}
+		if bat.power != tc.wantPower {
+			t.Fatalf("%s: got %fW, wanted %fW\n", tc.name, bat.power, tc.wantPower)
+		}
+		if bat.remaining != tc.wantRemaining {
+			t.Fatalf("%s: got %s remaining, wanted %s\n", tc.name, bat.remaining, tc.wantRemaining)
+		}
+	}
+}
+
+func TestBatteryDiscover(t *testing.T) {
+	root := t.TempDir()
+	supplies := map[string]map[string]string{
+		"BAT0":         {"type": "Battery", "capacity": "75", "status": "Discharging"},
+		"AC":           {"type": "Mains", "online": "1"},
+		"ucsi-source0": {"type": "USB", "online": "0"},
+		"hidpp_bat":    {"type": "Battery", "status": "Discharging"},
+	}
+	for name, files := range supplies {
+		dir := filepath.Join(root, name)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for file, contents := range files {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(contents+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tt := []struct {
+		name          string
+		adapters      bool
+		wantBatteries []string
+		wantAdapters  []adapterInfo
+	}{
+		{
+			name:          "batteries only",
+			wantBatteries: []string{"BAT0"},
+		},
+		{
+			name:          "with adapters",
+			adapters:      true,
+			wantBatteries: []string{"BAT0"},
+			wantAdapters:  []adapterInfo{{name: "AC", online: true}, {name: "ucsi-source0"}},
+		},
+	}
+
+	for _, tc := range tt {
+		b := &Battery{Adapters: tc.adapters, root: root}
+		if err := b.discover(); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		b.update()
+
+		if len(b.batteries) != len(tc.wantBatteries) {
+			t.Fatalf("%s: got %d batteries, wanted %d\n", tc.name, len(b.batteries), len(tc.wantBatteries))
+		}
+		for i, name := range tc.wantBatteries {
+			if b.batteries[i].name != name {
+				t.Fatalf("%s: got battery %s, wanted %s\n", tc.name, b.batteries[i].name, name)
+			}
+		}
+		if len(b.adapters) != len(tc.wantAdapters) {
+			t.Fatalf("%s: got %d adapters, wanted %d\n", tc.name, len(b.adapters), len(tc.wantAdapters))
+		}
+		for i, adapter := range tc.wantAdapters {
+			if b.adapters[i] != adapter {
+				t.Fatalf("%s: got adapter %+v, wanted %+v\n", tc.name, b.adapters[i], adapter)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package module
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/jmbaur/gobar/i3"
+)
+
+// parseClickBindings returns a dispatcher that runs the commands keyed by a
+// binding, such as "left", "shift+right" or "scroll_up".
+func parseClickBindings(onClick map[string]string) (*i3.Dispatcher, error) {
+	d := &i3.Dispatcher{}
+	for key, command := range onClick {
+		binding, err := i3.ParseBinding(key)
+		if err != nil {
+			return nil, err
+		}
+		command := command
+		d.Handle(binding, func(click i3.ClickEvent) {
+			spawnClickCommand(command, click)
+		})
+	}
+	return d, nil
+}
+
+// clickEnv returns the environment for a command run by a click, following
+// the variables set by i3blocks.
+func clickEnv(name, instance string, click *i3.ClickEvent) []string {
+	env := append(os.Environ(),
+		"BLOCK_NAME="+name,
+		"BLOCK_INSTANCE="+instance,
+	)
+	if click != nil {
+		env = append(env,
+			"BLOCK_BUTTON="+strconv.Itoa(click.Button),
+			"BLOCK_MODIFIERS="+strings.Join(click.Modifiers, ","),
+			"BLOCK_X="+strconv.Itoa(click.X),
+			"BLOCK_Y="+strconv.Itoa(click.Y),
+			"BLOCK_RELATIVE_X="+strconv.Itoa(click.RelativeX),
+			"BLOCK_RELATIVE_Y="+strconv.Itoa(click.RelativeY),
+			"BLOCK_WIDTH="+strconv.Itoa(click.Width),
+			"BLOCK_HEIGHT="+strconv.Itoa(click.Height),
+		)
+	}
+	return env
+}
+
+// spawnClickCommand runs a command bound to a click, detached from gobar's
+// session so it outlives gobar and doesn't receive its signals.
+func spawnClickCommand(command string, click i3.ClickEvent) {
+	proc := exec.Command("sh", "-c", command)
+	proc.Env = clickEnv(click.Name, click.Instance, &click)
+	proc.Stderr = os.Stderr
+	proc.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := proc.Start(); err != nil {
+		log.Printf("failed to run click binding: %v", err)
+		return
+	}
+	go func() {
+		_ = proc.Wait()
+	}()
+}
@@ -0,0 +1,144 @@
+package module
+
+import (
+	"testing"
+
+	"github.com/jmbaur/gobar/i3"
+)
+
+func TestParseClickBindings(t *testing.T) {
+	tt := []struct {
+		name    string
+		onClick map[string]string
+		click   i3.ClickEvent
+		wantErr bool
+		want    bool
+	}{
+		{
+			name:    "no bindings",
+			onClick: map[string]string{},
+			click:   i3.ClickEvent{Button: i3.LeftClick},
+			want:    false,
+		},
+		{
+			name:    "matching button",
+			onClick: map[string]string{"left": ":"},
+			click:   i3.ClickEvent{Button: i3.LeftClick},
+			want:    true,
+		},
+		{
+			name:    "matching modifiers",
+			onClick: map[string]string{"left": ":", "ctrl+shift+left": ":"},
+			click:   i3.ClickEvent{Button: i3.LeftClick, Modifiers: []string{"Shift", "Control"}},
+			want:    true,
+		},
+		{
+			name:    "missing modifier",
+			onClick: map[string]string{"ctrl+shift+left": ":"},
+			click:   i3.ClickEvent{Button: i3.LeftClick, Modifiers: []string{"Shift"}},
+			want:    false,
+		},
+		{
+			name:    "invalid button",
+			onClick: map[string]string{"left": ":", "top": ":"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid modifier",
+			onClick: map[string]string{"hyper+left": ":"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		d, err := parseClickBindings(tc.onClick)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected error\n", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if got := d.Dispatch(tc.click); got != tc.want {
+			t.Fatalf("%s: got %t, wanted %t\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDispatchClick(t *testing.T) {
+	tt := []struct {
+		name         string
+		override     bool
+		click        i3.ClickEvent
+		wantBinding  bool
+		wantPassedOn bool
+	}{
+		{
+			name:         "unbound click",
+			click:        i3.ClickEvent{Name: "cpu#2", Button: i3.LeftClick},
+			wantPassedOn: true,
+		},
+		{
+			name:         "bound click falls through",
+			click:        i3.ClickEvent{Name: "cpu#2", Button: i3.RightClick},
+			wantBinding:  true,
+			wantPassedOn: true,
+		},
+		{
+			name:         "bound click overrides",
+			override:     true,
+			click:        i3.ClickEvent{Name: "cpu#2", Button: i3.RightClick},
+			wantBinding:  true,
+			wantPassedOn: false,
+		},
+		{
+			name:         "unbound click with override",
+			override:     true,
+			click:        i3.ClickEvent{Name: "cpu#2", Button: i3.RightClick, Modifiers: []string{"Shift"}},
+			wantPassedOn: true,
+		},
+		{
+			name:  "other module",
+			click: i3.ClickEvent{Name: "cpu", Button: i3.RightClick},
+		},
+	}
+
+	for _, tc := range tt {
+		var bound []i3.ClickEvent
+		d := &i3.Dispatcher{}
+		d.HandleButtons(func(click i3.ClickEvent) {
+			bound = append(bound, click)
+		}, i3.RightClick)
+		state := []moduleState{{
+			id:              "cpu#2",
+			name:            "cpu",
+			clickChan:       make(chan i3.ClickEvent, 1),
+			onClick:         d,
+			onClickOverride: tc.override,
+		}}
+
+		dispatchClick(state, tc.click)
+
+		if got := len(bound) > 0; got != tc.wantBinding {
+			t.Fatalf("%s: got binding run %t, wanted %t\n", tc.name, got, tc.wantBinding)
+		}
+		if tc.wantBinding && bound[0].Name != "cpu" {
+			t.Fatalf("%s: got binding click name %q, wanted %q\n", tc.name, bound[0].Name, "cpu")
+		}
+		select {
+		case click := <-state[0].clickChan:
+			if !tc.wantPassedOn {
+				t.Fatalf("%s: click was passed on to the module\n", tc.name)
+			}
+			if click.Name != "cpu" {
+				t.Fatalf("%s: got click name %q, wanted %q\n", tc.name, click.Name, "cpu")
+			}
+		default:
+			if tc.wantPassedOn {
+				t.Fatalf("%s: click wasn't passed on to the module\n", tc.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package module
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+// sigRTMin is the first real-time signal available to programs linked against
+// glibc, which is what i3blocks uses as the base for its signal option.
+const sigRTMin = 34
+
+// urgentExitCode is the exit code a command can use to mark its block as
+// urgent.
+const urgentExitCode = 33
+
+var errCommandEmpty = errors.New("no command configured")
+
+// Command is a module that runs a command and prints its output following the
+// i3blocks protocol. The command is run at startup, on an interval, when a
+// signal is received and when the block is clicked. Only works on Linux.
+type Command struct {
+	// The command to run with "sh -c".
+	Command string `mapstructure:"command"`
+	// Passed to the command as BLOCK_NAME and BLOCK_INSTANCE.
+	Name     string `mapstructure:"name"`
+	Instance string `mapstructure:"instance"`
+	// How often to run the command. If zero, the command is only run at
+	// startup, on signals and on clicks.
+	Interval time.Duration `mapstructure:"interval"`
+	// Run the command when SIGRTMIN+Signal is received, for example with
+	// "pkill -RTMIN+1 gobar".
+	Signal int `mapstructure:"signal"`
+	// How long the command may run before it is killed. Defaults to 10
+	// seconds.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// The markup of the block if the command doesn't set one, for example
+	// "pango".
+	Markup string `mapstructure:"markup"`
+}
+
+func (cmd *Command) label() string {
+	if cmd.Name != "" {
+		return cmd.Name
+	}
+	return "command"
+}
+
+// commandResult is the outcome of a single run of the command.
+type commandResult struct {
+	block *i3.Block
+	err   error
+}
+
+// run runs the command and parses its output into a block.
+func (cmd *Command) run(click *i3.ClickEvent) (*i3.Block, error) {
+	var stdout, stderr bytes.Buffer
+	proc := exec.Command("sh", "-c", cmd.Command)
+	proc.Env = clickEnv(cmd.label(), cmd.Instance, click)
+	proc.Stdout = &stdout
+	proc.Stderr = &stderr
+	// Run the command in its own process group so that it can be killed
+	// along with any children on timeout.
+	proc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := proc.Start(); err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(cmd.Timeout, func() {
+		_ = syscall.Kill(-proc.Process.Pid, syscall.SIGKILL)
+	})
+	err := proc.Wait()
+	if !timer.Stop() {
+		return nil, fmt.Errorf("timed out after %s", cmd.Timeout)
+	}
+
+	urgent := false
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == urgentExitCode {
+		urgent = true
+	} else if err != nil {
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+
+	block, err := parseCommandOutput(stdout.Bytes())
+	if err != nil || block == nil {
+		return nil, err
+	}
+	block.Urgent = block.Urgent || urgent
+
+	return block, nil
+}
+
+// parseCommandOutput parses the output of a command following the i3blocks
+// protocol. The output is either a JSON object with the fields of an i3bar
+// block, or lines holding the full text, short text, color and background of
+// the block. A nil block is returned if the command printed nothing.
+func parseCommandOutput(out []byte) (*i3.Block, error) {
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	var block i3.Block
+	if trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &block); err != nil {
+			return nil, err
+		}
+	} else {
+		lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+		for i, line := range lines {
+			switch i {
+			case 0:
+				block.FullText = line
+			case 1:
+				block.ShortText = line
+			case 2:
+				block.Color = line
+			case 3:
+				block.Background = line
+			}
+		}
+	}
+
+	if block.FullText == "" {
+		return nil, nil
+	}
+
+	return &block, nil
+}
+
+func (cmd *Command) print(tx chan []i3.Block, block *i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:      "command",
+			Instance:  cmd.Instance,
+			FullText:  fmt.Sprintf("%s: %s", cmd.label(), err),
+			ShortText: fmt.Sprintf("%s: error", cmd.label()),
+			Color:     c.Red(),
+		}}
+		return
+	}
+
+	// i3blocks hides blocks without any text.
+	if block == nil {
+		tx <- []i3.Block{}
+		return
+	}
+
+	block.Name = "command"
+	if cmd.Instance != "" {
+		block.Instance = cmd.Instance
+	}
+	if block.Color == "" {
+		block.Color = c.Normal()
+	}
+	if block.Markup == "" {
+		block.Markup = cmd.Markup
+	}
+
+	tx <- []i3.Block{*block}
+}
+
+// Run implements Module.
+func (cmd *Command) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if cmd.Command == "" {
+		cmd.print(tx, nil, errCommandEmpty, c)
+		return
+	}
+
+	if cmd.Timeout <= 0 {
+		cmd.Timeout = 10 * time.Second
+	}
+
+	signals := make(chan os.Signal, 1)
+	if cmd.Signal > 0 {
+		signal.Notify(signals, syscall.Signal(sigRTMin+cmd.Signal))
+	}
+
+	ready := make(chan struct{}, 1)
+	results := make(chan commandResult)
+	defer func() {
+		signal.Stop(signals)
+		close(ready)
+		close(results)
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	// The command can take up to the timeout, so it is run in the background
+	// to keep handling clicks. Runs requested while the command is running
+	// are queued, each click getting its own run while other runs are
+	// coalesced since any run updates the block.
+	running := false
+	pending := []*i3.ClickEvent{}
+	start := func(click *i3.ClickEvent) {
+		if running {
+			if click != nil || len(pending) == 0 {
+				pending = append(pending, click)
+			}
+			return
+		}
+		running = true
+		go func() {
+			block, err := cmd.run(click)
+			results <- commandResult{block: block, err: err}
+		}()
+	}
+
+	for {
+		select {
+		case click := <-rx:
+			start(&click)
+		case <-signals:
+			start(nil)
+		case <-ready:
+			start(nil)
+
+			if cmd.Interval > 0 {
+				go func() {
+					time.Sleep(cmd.Interval)
+					ready <- struct{}{}
+				}()
+			}
+		case result := <-results:
+			running = false
+			cmd.print(tx, result.block, result.err, c)
+			if len(pending) > 0 {
+				click := pending[0]
+				pending = pending[1:]
+				start(click)
+			}
+		}
+	}
+}
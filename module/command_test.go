@@ -0,0 +1,169 @@
+package module
+
+import (
+	"testing"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+func TestParseCommandOutput(t *testing.T) {
+	tt := []struct {
+		name string
+		out  string
+		want *i3.Block
+	}{
+		{
+			name: "empty",
+			out:  "",
+			want: nil,
+		},
+		{
+			name: "full text only",
+			out:  "vol 42%\n",
+			want: &i3.Block{FullText: "vol 42%"},
+		},
+		{
+			name: "all lines",
+			out:  "vol 42%\n42%\n#ff0000\n#000000\n",
+			want: &i3.Block{FullText: "vol 42%", ShortText: "42%", Color: "#ff0000", Background: "#000000"},
+		},
+		{
+			name: "empty full text",
+			out:  "\n42%\n",
+			want: nil,
+		},
+		{
+			name: "json",
+			out:  `{"full_text": "vol 42%", "color": "#ff0000", "min_width": "vol 100%", "urgent": true}`,
+			want: &i3.Block{FullText: "vol 42%", Color: "#ff0000", MinWidthText: "vol 100%", Urgent: true},
+		},
+		{
+			name: "json with pixel min width",
+			out:  `{"full_text": "vol 42%", "min_width": 100}`,
+			want: &i3.Block{FullText: "vol 42%", MinWidth: 100},
+		},
+	}
+
+	for _, tc := range tt {
+		got, err := parseCommandOutput([]byte(tc.out))
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCommandRun(t *testing.T) {
+	tt := []struct {
+		name    string
+		cmd     Command
+		click   *i3.ClickEvent
+		want    *i3.Block
+		wantErr bool
+	}{
+		{
+			name: "click environment",
+			cmd:  Command{Command: `echo "$BLOCK_NAME $BLOCK_BUTTON $BLOCK_X"`, Name: "vol"},
+			click: &i3.ClickEvent{
+				Button: i3.LeftClick,
+				X:      42,
+			},
+			want: &i3.Block{FullText: "vol 1 42"},
+		},
+		{
+			name: "urgent exit code",
+			cmd:  Command{Command: "echo hot; exit 33"},
+			want: &i3.Block{FullText: "hot", Urgent: true},
+		},
+		{
+			name:    "failure",
+			cmd:     Command{Command: "echo oops >&2; exit 1"},
+			wantErr: true,
+		},
+		{
+			name:    "timeout",
+			cmd:     Command{Command: "sleep 10", Timeout: 100 * time.Millisecond},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		if tc.cmd.Timeout == 0 {
+			tc.cmd.Timeout = 10 * time.Second
+		}
+		got, err := tc.cmd.run(tc.click)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, wanted error %v\n", tc.name, err, tc.wantErr)
+		}
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCommandRunHandlesClicksWhileRunning(t *testing.T) {
+	cmd := &Command{Command: `sleep 0.3; echo "run ${BLOCK_BUTTON:-none}"`}
+	tx := make(chan []i3.Block)
+	rx := make(chan i3.ClickEvent)
+	go cmd.Run(tx, rx, col.Color{})
+
+	// The first run is still sleeping, so the click is only accepted if the
+	// command runs in the background.
+	select {
+	case rx <- i3.ClickEvent{Button: i3.LeftClick}:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("click blocked while the command was running\n")
+	}
+
+	// The click and the initial run can be handled in either order.
+	want := map[string]bool{"run none": true, "run 1": true}
+	for len(want) > 0 {
+		select {
+		case blocks := <-tx:
+			if len(blocks) != 1 || !want[blocks[0].FullText] {
+				t.Fatalf("got %+v, wanted one of %v\n", blocks, want)
+			}
+			delete(want, blocks[0].FullText)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v\n", want)
+		}
+	}
+}
+
+func TestCommandRunQueuesClicks(t *testing.T) {
+	// Interval runs are requested while the clicks wait for the command.
+	cmd := &Command{Command: `sleep 0.2; echo "run ${BLOCK_BUTTON:-none}"`, Interval: 50 * time.Millisecond}
+	tx := make(chan []i3.Block)
+	rx := make(chan i3.ClickEvent)
+	go cmd.Run(tx, rx, col.Color{})
+
+	for _, button := range []int{i3.LeftClick, i3.RightClick} {
+		select {
+		case rx <- i3.ClickEvent{Button: button}:
+		case <-time.After(time.Second):
+			t.Fatalf("click blocked while the command was running\n")
+		}
+	}
+
+	want := []string{"run 1", "run 3"}
+	for len(want) > 0 {
+		select {
+		case blocks := <-tx:
+			if len(blocks) != 1 {
+				t.Fatalf("got %+v, wanted one block\n", blocks)
+			}
+			if text := blocks[0].FullText; text != "run none" {
+				if text != want[0] {
+					t.Fatalf("got %q, wanted %q\n", text, want[0])
+				}
+				want = want[1:]
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v\n", want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package module
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+var errCPUNoStats = errors.New("no cpu statistics")
+
+var graphBars = []rune("▁▂▃▄▅▆▇█")
+
+// cpuTimes holds the time spent by a CPU in each state, as found in
+// /proc/stat.
+type cpuTimes struct {
+	user, nice, system, idle, iowait, irq, softirq, steal uint64
+}
+
+func (t cpuTimes) total() uint64 {
+	return t.user + t.nice + t.system + t.idle + t.iowait + t.irq + t.softirq + t.steal
+}
+
+// cpuUsage holds the percentage of time a CPU spent in each state between two
+// samples.
+type cpuUsage struct {
+	total, user, system, iowait float64
+}
+
+func newCPUUsage(prev, cur cpuTimes) cpuUsage {
+	// Counters can go backwards when a core is taken offline.
+	if cur.total() <= prev.total() {
+		return cpuUsage{}
+	}
+	elapsed := float64(cur.total() - prev.total())
+	percent := func(prev, cur uint64) float64 {
+		if cur < prev {
+			return 0
+		}
+		return float64(cur-prev) / elapsed * 100
+	}
+	idle := percent(prev.idle+prev.iowait, cur.idle+cur.iowait)
+	return cpuUsage{
+		total:  100 - idle,
+		user:   percent(prev.user+prev.nice, cur.user+cur.nice),
+		system: percent(prev.system+prev.irq+prev.softirq, cur.system+cur.irq+cur.softirq),
+		iowait: percent(prev.iowait, cur.iowait),
+	}
+}
+
+// parseProcStat returns the aggregate and per-core CPU times from the contents
+// of /proc/stat.
+func parseProcStat(data []byte) (cpuTimes, []cpuTimes, error) {
+	var aggregate cpuTimes
+	cores := []cpuTimes{}
+	found := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+
+		values := make([]uint64, 8)
+		for i := range values {
+			v, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return aggregate, nil, err
+			}
+			values[i] = v
+		}
+		times := cpuTimes{
+			user:    values[0],
+			nice:    values[1],
+			system:  values[2],
+			idle:    values[3],
+			iowait:  values[4],
+			irq:     values[5],
+			softirq: values[6],
+			steal:   values[7],
+		}
+
+		if fields[0] == "cpu" {
+			aggregate = times
+			found = true
+		} else {
+			cores = append(cores, times)
+		}
+	}
+
+	if !found {
+		return aggregate, nil, errCPUNoStats
+	}
+
+	return aggregate, cores, nil
+}
+
+// CPU provides information on CPU utilization, sampled from /proc/stat. Only
+// works on Linux.
+type CPU struct {
+	// Whether to show the utilization of each core.
+	PerCore bool `mapstructure:"per_core"`
+	// Whether to render the utilization of each core as a bar graph instead
+	// of percentages.
+	Graph bool `mapstructure:"graph"`
+	// Whether to show the user, system and iowait breakdown. Can be toggled
+	// with a click.
+	Breakdown bool `mapstructure:"breakdown"`
+	// Utilization percentages at which the block is colored yellow and red.
+	// Default to 50 and 80.
+	Warning  float64 `mapstructure:"warning"`
+	Critical float64 `mapstructure:"critical"`
+	// How often to sample /proc/stat. Defaults to 5 seconds.
+	Interval time.Duration `mapstructure:"interval"`
+
+	// whether usage has been computed from two samples yet
+	sampled bool
+	usage   cpuUsage
+	cores   []cpuUsage
+}
+
+func (cpu *CPU) color(percent float64, c col.Color) string {
+	switch {
+	case percent > cpu.Critical:
+		return c.Red()
+	case percent > cpu.Warning:
+		return c.Yellow()
+	default:
+		return c.Normal()
+	}
+}
+
+func (cpu *CPU) print(tx chan []i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:     "cpu",
+			Instance: "cpu",
+			FullText: fmt.Sprintf("CPU: %s", err),
+			Color:    c.Red(),
+			Urgent:   true,
+		}}
+		return
+	}
+	if !cpu.sampled {
+		tx <- []i3.Block{{
+			Name:      "cpu",
+			Instance:  "cpu",
+			FullText:  "CPU: …",
+			ShortText: "CPU: …",
+			Color:     c.Normal(),
+		}}
+		return
+	}
+
+	short := fmt.Sprintf("CPU: %d%%", int(cpu.usage.total))
+	text := short
+
+	if cpu.Breakdown {
+		text += fmt.Sprintf(" (us %d%% sy %d%% wa %d%%)",
+			int(cpu.usage.user), int(cpu.usage.system), int(cpu.usage.iowait))
+	}
+
+	if cpu.PerCore && len(cpu.cores) > 0 {
+		cores := []string{}
+		for _, core := range cpu.cores {
+			if cpu.Graph {
+				idx := int(core.total / 100 * float64(len(graphBars)))
+				if idx >= len(graphBars) {
+					idx = len(graphBars) - 1
+				} else if idx < 0 {
+					idx = 0
+				}
+				cores = append(cores, string(graphBars[idx]))
+			} else {
+				cores = append(cores, fmt.Sprintf("%d%%", int(core.total)))
+			}
+		}
+		if cpu.Graph {
+			text += " " + strings.Join(cores, "")
+		} else {
+			text += " " + strings.Join(cores, " ")
+		}
+	}
+
+	tx <- []i3.Block{{
+		Name:      "cpu",
+		Instance:  "cpu",
+		FullText:  text,
+		ShortText: short,
+		Color:     cpu.color(cpu.usage.total, c),
+		Urgent:    cpu.usage.total > cpu.Critical,
+	}}
+}
+
+// Run implements Module.
+func (cpu *CPU) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if cpu.Warning == 0 {
+		cpu.Warning = 50
+	}
+	if cpu.Critical == 0 {
+		cpu.Critical = 80
+	}
+	if cpu.Interval <= 0 {
+		cpu.Interval = 5 * time.Second
+	}
+
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		cpu.print(tx, err, c)
+		return
+	}
+
+	ready := make(chan struct{}, 1)
+	defer func() {
+		f.Close()
+		close(ready)
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	clicks := &i3.Dispatcher{}
+	clicks.HandleButtons(func(i3.ClickEvent) {
+		cpu.Breakdown = !cpu.Breakdown
+		cpu.print(tx, nil, c)
+	}, i3.LeftClick, i3.RightClick)
+
+	// Show a placeholder until the first usage is computed an interval
+	// from now.
+	cpu.print(tx, nil, c)
+
+	var prev cpuTimes
+	var prevCores []cpuTimes
+	first := true
+
+	for {
+		select {
+		case click := <-rx:
+			clicks.Dispatch(click)
+		case <-ready:
+			go func() {
+				time.Sleep(cpu.Interval)
+				ready <- struct{}{}
+			}()
+
+			data, err := io.ReadAll(f)
+			if err != nil {
+				cpu.print(tx, err, c)
+				continue
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				cpu.print(tx, err, c)
+				continue
+			}
+
+			cur, curCores, err := parseProcStat(data)
+			if err != nil {
+				cpu.print(tx, err, c)
+				continue
+			}
+
+			// Usage can only be computed once there are two samples, the
+			// first one only holds the times since boot.
+			if first {
+				prev, prevCores, first = cur, curCores, false
+				continue
+			}
+
+			cpu.usage = newCPUUsage(prev, cur)
+			cpu.cores = cpu.cores[:0]
+			for i, core := range curCores {
+				var prevCore cpuTimes
+				if i < len(prevCores) {
+					prevCore = prevCores[i]
+				}
+				cpu.cores = append(cpu.cores, newCPUUsage(prevCore, core))
+			}
+			prev, prevCores = cur, curCores
+			cpu.sampled = true
+
+			cpu.print(tx, nil, c)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package module
+
+import (
+	"testing"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+func TestParseProcStat(t *testing.T) {
+	tt := []struct {
+		name          string
+		data          string
+		wantAggregate cpuTimes
+		wantCores     []cpuTimes
+		wantErr       bool
+	}{
+		{
+			name: "aggregate and cores",
+			data: `cpu  10132153 290696 3084719 46828483 16683 0 25195 0 0 0
+cpu0 1393280 32966 572056 13343292 6130 0 17875 0 0 0
+cpu1 1335009 46553 482005 13425618 2710 0 3000 0 0 0
+intr 199292311 57 0 0 0 0 0 0 0 1 0 0 0 0 0 0 0 0 0 0
+ctxt 295829015
+btime 1701234567
+processes 3425011
+`,
+			wantAggregate: cpuTimes{user: 10132153, nice: 290696, system: 3084719, idle: 46828483, iowait: 16683, softirq: 25195},
+			wantCores: []cpuTimes{
+				{user: 1393280, nice: 32966, system: 572056, idle: 13343292, iowait: 6130, softirq: 17875},
+				{user: 1335009, nice: 46553, system: 482005, idle: 13425618, iowait: 2710, softirq: 3000},
+			},
+		},
+		{
+			name: "steal time",
+			data: "cpu  100 0 50 800 10 5 5 30 0 0\n",
+			wantAggregate: cpuTimes{
+				user: 100, system: 50, idle: 800, iowait: 10, irq: 5, softirq: 5, steal: 30,
+			},
+			wantCores: []cpuTimes{},
+		},
+		{
+			name:    "no aggregate",
+			data:    "intr 199292311 57 0 0 0 0 0 0 0 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid number",
+			data:    "cpu  abc 0 0 0 0 0 0 0 0 0\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		aggregate, cores, err := parseProcStat([]byte(tc.data))
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, wanted error %v\n", tc.name, err, tc.wantErr)
+		}
+		if tc.wantErr {
+			continue
+		}
+		if aggregate != tc.wantAggregate {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, aggregate, tc.wantAggregate)
+		}
+		if len(cores) != len(tc.wantCores) {
+			t.Fatalf("%s: got %d cores, wanted %d\n", tc.name, len(cores), len(tc.wantCores))
+		}
+		for i := range cores {
+			if cores[i] != tc.wantCores[i] {
+				t.Fatalf("%s: got core %d %+v, wanted %+v\n", tc.name, i, cores[i], tc.wantCores[i])
+			}
+		}
+	}
+}
+
+func TestNewCPUUsage(t *testing.T) {
+	tt := []struct {
+		name string
+		prev cpuTimes
+		cur  cpuTimes
+		want cpuUsage
+	}{
+		{
+			name: "idle",
+			prev: cpuTimes{user: 100, idle: 1000},
+			cur:  cpuTimes{user: 100, idle: 1100},
+			want: cpuUsage{},
+		},
+		{
+			name: "busy",
+			prev: cpuTimes{user: 100, system: 100, idle: 1000},
+			cur:  cpuTimes{user: 150, nice: 10, system: 120, irq: 5, softirq: 5, idle: 1010},
+			want: cpuUsage{total: 90, user: 60, system: 30},
+		},
+		{
+			name: "iowait counts as idle",
+			prev: cpuTimes{user: 0, idle: 0, iowait: 0},
+			cur:  cpuTimes{user: 50, idle: 25, iowait: 25},
+			want: cpuUsage{total: 50, user: 50, iowait: 25},
+		},
+		{
+			name: "core taken offline",
+			prev: cpuTimes{user: 100, idle: 1000},
+			cur:  cpuTimes{user: 10, idle: 20},
+			want: cpuUsage{},
+		},
+		{
+			name: "iowait going backwards",
+			prev: cpuTimes{user: 0, idle: 0, iowait: 50},
+			cur:  cpuTimes{user: 60, idle: 150, iowait: 40},
+			want: cpuUsage{total: 30, user: 30},
+		},
+	}
+
+	for _, tc := range tt {
+		if got := newCPUUsage(tc.prev, tc.cur); got != tc.want {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCPUPrint(t *testing.T) {
+	c := col.Color{}
+	tt := []struct {
+		name      string
+		cpu       CPU
+		wantText  string
+		wantColor string
+	}{
+		{
+			name:      "before the first delta",
+			cpu:       CPU{Warning: 50, Critical: 80, Breakdown: true},
+			wantText:  "CPU: …",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "sampled",
+			cpu:       CPU{Warning: 50, Critical: 80, sampled: true, usage: cpuUsage{total: 60}},
+			wantText:  "CPU: 60%",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "idle",
+			cpu:       CPU{Warning: 50, Critical: 80, sampled: true},
+			wantText:  "CPU: 0%",
+			wantColor: c.Normal(),
+		},
+	}
+
+	for _, tc := range tt {
+		tx := make(chan []i3.Block, 1)
+		tc.cpu.print(tx, nil, c)
+		blocks := <-tx
+		if blocks[0].FullText != tc.wantText {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, blocks[0].FullText, tc.wantText)
+		}
+		if blocks[0].Color != tc.wantColor {
+			t.Fatalf("%s: got color %q, wanted %q\n", tc.name, blocks[0].Color, tc.wantColor)
+		}
+	}
+}
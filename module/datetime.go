@@ -14,7 +14,7 @@ type Datetime struct {
 	// For example: Local, UTC, Europe/Zurich, etc.
 	Timezones []string `mapstructure:"timezones"`
 	// Whether to show all timezones at once. If false, the timezones can be
-	// toggled with a middle click.
+	// cycled with left and right clicks or by scrolling.
 	ShowAllTimezones bool `mapstructure:"show_all_timezones"`
 
 	currentLocation *time.Location
@@ -34,12 +34,12 @@ func (d *Datetime) print(tx chan []i3.Block, t time.Time, c col.Color) {
 				longFormat = d.shortFormat
 			}
 			blocks = append(blocks, i3.Block{
-				Name:      "datetime",
-				Instance:  loc.String(),
-				FullText:  t.In(loc).Format(longFormat),
-				Color:     c.Normal(),
-				ShortText: t.In(loc).Format(d.shortFormat),
-				MinWidth:  len(d.shortFormat),
+				Name:         "datetime",
+				Instance:     loc.String(),
+				FullText:     t.In(loc).Format(longFormat),
+				Color:        c.Normal(),
+				ShortText:    t.In(loc).Format(d.shortFormat),
+				MinWidthText: d.shortFormat,
 			})
 		}
 	} else {
@@ -48,18 +48,37 @@ func (d *Datetime) print(tx chan []i3.Block, t time.Time, c col.Color) {
 			longFormat = d.shortFormat
 		}
 		blocks = []i3.Block{{
-			Name:      "datetime",
-			Instance:  d.currentLocation.String(),
-			FullText:  t.In(d.currentLocation).Format(longFormat),
-			Color:     c.Normal(),
-			ShortText: t.In(d.currentLocation).Format(d.shortFormat),
-			MinWidth:  len(d.shortFormat),
+			Name:         "datetime",
+			Instance:     d.currentLocation.String(),
+			FullText:     t.In(d.currentLocation).Format(longFormat),
+			Color:        c.Normal(),
+			ShortText:    t.In(d.currentLocation).Format(d.shortFormat),
+			MinWidthText: d.shortFormat,
 		}}
 	}
 
 	tx <- blocks
 }
 
+// cycle changes the current location to the one direction steps away from
+// the location shown in instance, wrapping around the configured timezones.
+func (d *Datetime) cycle(instance string, direction int) {
+	idx := slices.IndexFunc(d.locations, func(loc *time.Location) bool {
+		return loc.String() == instance
+	})
+	if idx < 0 {
+		return
+	}
+
+	newIdx := idx + direction
+	if newIdx >= len(d.locations) {
+		newIdx = 0
+	} else if newIdx < 0 {
+		newIdx = len(d.locations) - 1
+	}
+	d.currentLocation = d.locations[newIdx]
+}
+
 // Run implements Module.
 func (d *Datetime) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 	d.shortFormat = "15:04:05 MST"
@@ -95,6 +114,17 @@ func (d *Datetime) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 	// Start at the first configured timezone.
 	d.currentLocation = d.locations[0]
 
+	clicks := &i3.Dispatcher{}
+	clicks.HandleButtons(func(i3.ClickEvent) {
+		d.verbose = !d.verbose
+	}, i3.MiddleClick)
+	clicks.HandleButtons(func(click i3.ClickEvent) {
+		d.cycle(click.Instance, 1)
+	}, i3.LeftClick, i3.ScrollUp)
+	clicks.HandleButtons(func(click i3.ClickEvent) {
+		d.cycle(click.Instance, -1)
+	}, i3.RightClick, i3.ScrollDown)
+
 	ready := make(chan struct{}, 1)
 	defer close(ready)
 
@@ -105,33 +135,9 @@ func (d *Datetime) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 	for {
 		select {
 		case click := <-rx:
-			direction := 0
-			idx := slices.IndexFunc(d.locations, func(loc *time.Location) bool {
-				return loc.String() == click.Instance
-			})
-			if idx < 0 || idx > len(d.locations)-1 {
-				continue
-			}
-			switch click.Button {
-			case i3.MiddleClick:
-				d.verbose = !d.verbose
-			case i3.LeftClick:
-				direction = 1
-			case i3.RightClick:
-				direction = -1
-			}
-
-			if direction != 0 {
-				newIdx := idx + direction
-				if newIdx >= len(d.locations) {
-					newIdx = 0
-				} else if newIdx < 0 {
-					newIdx = len(d.locations) - 1
-				}
-				d.currentLocation = d.locations[newIdx]
+			if clicks.Dispatch(click) {
+				d.print(tx, time.Now(), c)
 			}
-
-			d.print(tx, time.Now(), c)
 		case <-ready:
 			d.print(tx, time.Now(), c)
 			go func() {
@@ -0,0 +1,282 @@
+package module
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"golang.org/x/exp/slices"
+	"golang.org/x/sys/unix"
+)
+
+var errDiskNoMounts = errors.New("no mountpoints")
+
+// defaultDiskExclude are the filesystem types that aren't backed by a disk or
+// a network share.
+var defaultDiskExclude = []string{
+	"tmpfs", "overlay", "devtmpfs", "ramfs", "squashfs", "efivarfs",
+	"proc", "sysfs", "cgroup", "cgroup2", "devpts", "mqueue", "securityfs",
+	"debugfs", "tracefs", "pstore", "bpf", "configfs", "fusectl", "hugetlbfs",
+	"autofs", "binfmt_misc", "nsfs", "selinuxfs", "rpc_pipefs", "nfsd",
+}
+
+// humanBytes formats a number of bytes using binary prefixes, for example
+// "1.5GiB".
+func humanBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", int(n), units[i])
+	}
+	return fmt.Sprintf("%.1f%s", n, units[i])
+}
+
+// unescapeMountinfo replaces the octal escapes used for whitespace and
+// backslashes in /proc/self/mountinfo, such as "\040" for a space.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// diskMountpoints returns the mountpoints of filesystems from
+// /proc/self/mountinfo, skipping the excluded filesystem types.
+func diskMountpoints(exclude []string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseMountinfo(f, exclude)
+}
+
+// parseMountinfo returns the mountpoints in the mountinfo format, skipping the
+// excluded filesystem types and additional mounts of the same filesystem.
+// Sources aren't required to be devices, so that ZFS datasets and network
+// shares such as NFS and CIFS are included.
+func parseMountinfo(r io.Reader, exclude []string) ([]string, error) {
+	mountpoints := []string{}
+	devices := map[string]struct{}{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw
+		fields := strings.Fields(scanner.Text())
+		sep := slices.Index(fields, "-")
+		if sep < 5 || sep+1 >= len(fields) {
+			continue
+		}
+		device, mountpoint := fields[2], unescapeMountinfo(fields[4])
+		fsType := fields[sep+1]
+
+		if slices.Contains(exclude, fsType) {
+			continue
+		}
+		if _, ok := devices[device]; ok {
+			continue
+		}
+		devices[device] = struct{}{}
+
+		mountpoints = append(mountpoints, mountpoint)
+	}
+
+	return mountpoints, scanner.Err()
+}
+
+type diskUsage struct {
+	mountpoint  string
+	err         error
+	total       float64
+	free        float64
+	used        float64
+	usedPercent float64
+	inodePct    float64
+}
+
+// Disk provides the used and free space of filesystems. Only works on Linux.
+type Disk struct {
+	// The mountpoints to show. Defaults to all filesystems that aren't
+	// excluded.
+	Mountpoints []string `mapstructure:"mountpoints"`
+	// Filesystem types to skip when no mountpoints are configured. Defaults
+	// to tmpfs, overlay, devtmpfs, ramfs, squashfs, efivarfs and the kernel's
+	// pseudo filesystems such as proc, sysfs and cgroup2.
+	Exclude []string `mapstructure:"exclude"`
+	// Whether to show "free" (the default) or "used" space.
+	Show string `mapstructure:"show"`
+	// Whether to show the percentage of used inodes.
+	Inodes bool `mapstructure:"inodes"`
+	// Percentages of used space or inodes at which the block is colored
+	// yellow and red. Default to 80 and 90.
+	Warning  float64 `mapstructure:"warning"`
+	Critical float64 `mapstructure:"critical"`
+	// How often to check the filesystems. Defaults to 30 seconds.
+	Interval time.Duration `mapstructure:"interval"`
+
+	usage []diskUsage
+}
+
+func (d *Disk) print(tx chan []i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:     "disk",
+			Instance: "disk",
+			FullText: fmt.Sprintf("DISK: %s", err),
+			Color:    c.Red(),
+		}}
+		return
+	}
+
+	blocks := []i3.Block{}
+	for _, u := range d.usage {
+		if u.err != nil {
+			blocks = append(blocks, i3.Block{
+				Name:      "disk",
+				Instance:  u.mountpoint,
+				FullText:  fmt.Sprintf("%s: %s", u.mountpoint, u.err),
+				ShortText: fmt.Sprintf("%s: error", u.mountpoint),
+				Color:     c.Red(),
+			})
+			continue
+		}
+
+		percent := u.usedPercent
+		if d.Inodes && u.inodePct > percent {
+			percent = u.inodePct
+		}
+
+		urgent := false
+		color := c.Normal()
+		switch {
+		case percent > d.Critical:
+			color = c.Red()
+			urgent = true
+		case percent > d.Warning:
+			color = c.Yellow()
+		}
+
+		short := fmt.Sprintf("%s: %s free", u.mountpoint, humanBytes(u.free))
+		if d.Show == "used" {
+			short = fmt.Sprintf("%s: %s/%s used", u.mountpoint, humanBytes(u.used), humanBytes(u.total))
+		}
+		text := short
+		if d.Inodes {
+			text += fmt.Sprintf(" (inodes %d%%)", int(u.inodePct))
+		}
+
+		blocks = append(blocks, i3.Block{
+			Name:      "disk",
+			Instance:  u.mountpoint,
+			FullText:  text,
+			ShortText: short,
+			Color:     color,
+			Urgent:    urgent,
+		})
+	}
+
+	tx <- blocks
+}
+
+func statDisk(mountpoint string) diskUsage {
+	u := diskUsage{mountpoint: mountpoint}
+
+	var stat unix.Statfs_t
+	if err := unix.Statfs(mountpoint, &stat); err != nil {
+		u.err = err
+		return u
+	}
+
+	bsize := float64(stat.Bsize)
+	u.total = float64(stat.Blocks) * bsize
+	u.free = float64(stat.Bavail) * bsize
+	u.used = float64(stat.Blocks-stat.Bfree) * bsize
+	// Match df by excluding the blocks reserved for root.
+	if u.used+u.free > 0 {
+		u.usedPercent = u.used / (u.used + u.free) * 100
+	}
+	if stat.Files > 0 {
+		u.inodePct = float64(stat.Files-stat.Ffree) / float64(stat.Files) * 100
+	}
+
+	return u
+}
+
+// Run implements Module.
+func (d *Disk) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if d.Exclude == nil {
+		d.Exclude = defaultDiskExclude
+	}
+	if d.Warning == 0 {
+		d.Warning = 80
+	}
+	if d.Critical == 0 {
+		d.Critical = 90
+	}
+	if d.Interval <= 0 {
+		d.Interval = 30 * time.Second
+	}
+
+	ready := make(chan struct{}, 1)
+	defer close(ready)
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	for {
+		select {
+		// no click support for disk
+		case <-rx:
+		case <-ready:
+			go func() {
+				time.Sleep(d.Interval)
+				ready <- struct{}{}
+			}()
+
+			mountpoints := d.Mountpoints
+			if len(mountpoints) == 0 {
+				var err error
+				mountpoints, err = diskMountpoints(d.Exclude)
+				if err != nil {
+					d.print(tx, err, c)
+					continue
+				}
+			}
+			if len(mountpoints) == 0 {
+				d.print(tx, errDiskNoMounts, c)
+				continue
+			}
+
+			d.usage = d.usage[:0]
+			for _, mountpoint := range mountpoints {
+				d.usage = append(d.usage, statDisk(mountpoint))
+			}
+
+			d.print(tx, nil, c)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package module
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestParseMountinfo(t *testing.T) {
+	mountinfo := `22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:5 - proc proc rw
+24 22 0:22 / /sys rw,nosuid,nodev,noexec,relatime shared:6 - sysfs sysfs rw
+25 22 0:5 / /dev rw,nosuid shared:2 - devtmpfs devtmpfs rw,size=8087912k
+26 22 0:23 / /run rw,nosuid,nodev shared:12 - tmpfs tmpfs rw,mode=755
+27 22 0:45 / /home rw,relatime shared:20 - zfs pool/home rw,xattr,noacl
+28 22 0:50 / /mnt/nas rw,relatime shared:30 - nfs4 nas:/export rw,vers=4.2
+29 22 0:51 / /mnt/share rw,relatime shared:31 - cifs //host/share rw
+30 22 259:2 /var/lib/docker /var/lib/docker rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+31 22 259:3 / /mnt/usb\040drive rw,relatime shared:40 - vfat /dev/sda1 rw
+`
+
+	tt := []struct {
+		name    string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "default exclude",
+			exclude: defaultDiskExclude,
+			want:    []string{"/", "/home", "/mnt/nas", "/mnt/share", "/mnt/usb drive"},
+		},
+		{
+			name:    "exclude network shares",
+			exclude: append([]string{"nfs4", "cifs"}, defaultDiskExclude...),
+			want:    []string{"/", "/home", "/mnt/usb drive"},
+		},
+		{
+			name: "no exclude",
+			want: []string{"/", "/proc", "/sys", "/dev", "/run", "/home", "/mnt/nas", "/mnt/share", "/mnt/usb drive"},
+		},
+	}
+
+	for _, tc := range tt {
+		got, err := parseMountinfo(strings.NewReader(mountinfo), tc.exclude)
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestUnescapeMountinfo(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "no escapes", in: "/mnt/usb", want: "/mnt/usb"},
+		{name: "space", in: `/mnt/usb\040drive`, want: "/mnt/usb drive"},
+		{name: "tab", in: `/mnt/a\011b`, want: "/mnt/a\tb"},
+		{name: "newline", in: `/mnt/a\012b`, want: "/mnt/a\nb"},
+		{name: "backslash", in: `/mnt/a\134b`, want: `/mnt/a\b`},
+		{name: "escape at end", in: `/mnt/usb\040`, want: "/mnt/usb "},
+		{name: "multiple", in: `/mnt/my\040usb\040drive`, want: "/mnt/my usb drive"},
+		{name: "not octal", in: `/mnt/a\xyz`, want: `/mnt/a\xyz`},
+		{name: "truncated", in: `/mnt/a\04`, want: `/mnt/a\04`},
+	}
+
+	for _, tc := range tt {
+		if got := unescapeMountinfo(tc.in); got != tc.want {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package module
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// sectorSize is the unit of the sector counts in /proc/diskstats, regardless
+// of the device's actual sector size.
+const sectorSize = 512
+
+// defaultDiskIOPattern matches whole disks, skipping partitions, loop devices
+// and device mapper targets.
+const defaultDiskIOPattern = `^(sd[a-z]+|vd[a-z]+|xvd[a-z]+|nvme[0-9]+n[0-9]+|mmcblk[0-9]+)$`
+
+// diskStats holds the cumulative counters of a block device from
+// /proc/diskstats.
+type diskStats struct {
+	sectorsRead    uint64
+	sectorsWritten uint64
+	// Milliseconds spent doing I/O.
+	ioTicks uint64
+}
+
+// parseDiskstats returns the counters of each block device from the contents
+// of /proc/diskstats.
+func parseDiskstats(data []byte) (map[string]diskStats, error) {
+	stats := map[string]diskStats{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		//  259  0 nvme0n1 1000 0 2000 300 4000 0 5000 600 0 700 900 ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+
+		values := []uint64{}
+		for _, idx := range []int{5, 9, 12} {
+			v, err := strconv.ParseUint(fields[idx], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+
+		stats[fields[2]] = diskStats{
+			sectorsRead:    values[0],
+			sectorsWritten: values[1],
+			ioTicks:        values[2],
+		}
+	}
+
+	return stats, scanner.Err()
+}
+
+// diskRate is the throughput and utilization of a block device between two
+// samples.
+type diskRate struct {
+	device      string
+	readPerSec  float64
+	writePerSec float64
+	utilization float64
+}
+
+func newDiskRate(device string, prev, cur diskStats, elapsed time.Duration) diskRate {
+	delta := func(prev, cur uint64) float64 {
+		if cur < prev {
+			return 0
+		}
+		return float64(cur - prev)
+	}
+	secs := elapsed.Seconds()
+	r := diskRate{
+		device:      device,
+		readPerSec:  delta(prev.sectorsRead, cur.sectorsRead) * sectorSize / secs,
+		writePerSec: delta(prev.sectorsWritten, cur.sectorsWritten) * sectorSize / secs,
+		utilization: delta(prev.ioTicks, cur.ioTicks) / float64(elapsed.Milliseconds()) * 100,
+	}
+	if r.utilization > 100 {
+		r.utilization = 100
+	}
+	return r
+}
+
+// DiskIO provides the read and write throughput and utilization of block
+// devices, sampled from /proc/diskstats. Only works on Linux.
+type DiskIO struct {
+	// A regexp matched on device names, such as "nvme0n1" or "sda". Defaults
+	// to matching whole disks.
+	Pattern *string
+	// Whether to sum the throughput of all matching devices into one block.
+	// The utilization shown is that of the busiest device.
+	Aggregate bool `mapstructure:"aggregate"`
+	// Utilization percentages at which the block is colored yellow and red.
+	// Default to 50 and 90.
+	Warning  float64 `mapstructure:"warning"`
+	Critical float64 `mapstructure:"critical"`
+	// How often to sample /proc/diskstats. Defaults to 5 seconds.
+	Interval time.Duration `mapstructure:"interval"`
+
+	patternRe *regexp.Regexp
+	rates     []diskRate
+}
+
+func (d *DiskIO) block(name, instance string, rate diskRate, c col.Color) i3.Block {
+	urgent := false
+	color := c.Normal()
+	switch {
+	case rate.utilization > d.Critical:
+		color = c.Red()
+		urgent = true
+	case rate.utilization > d.Warning:
+		color = c.Yellow()
+	}
+
+	short := fmt.Sprintf("%s: %d%%", name, int(rate.utilization))
+	return i3.Block{
+		Name:     "diskio",
+		Instance: instance,
+		FullText: fmt.Sprintf("%s: R %s/s W %s/s %d%%", name,
+			humanBytes(rate.readPerSec), humanBytes(rate.writePerSec), int(rate.utilization)),
+		ShortText: short,
+		Color:     color,
+		Urgent:    urgent,
+	}
+}
+
+func (d *DiskIO) print(tx chan []i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:     "diskio",
+			Instance: "diskio",
+			FullText: fmt.Sprintf("IO: %s", err),
+			Color:    c.Red(),
+		}}
+		return
+	}
+
+	if d.Aggregate {
+		total := diskRate{}
+		for _, rate := range d.rates {
+			total.readPerSec += rate.readPerSec
+			total.writePerSec += rate.writePerSec
+			if rate.utilization > total.utilization {
+				total.utilization = rate.utilization
+			}
+		}
+		tx <- []i3.Block{d.block("IO", "diskio", total, c)}
+		return
+	}
+
+	blocks := []i3.Block{}
+	for _, rate := range d.rates {
+		blocks = append(blocks, d.block(rate.device, rate.device, rate, c))
+	}
+	tx <- blocks
+}
+
+// Run implements Module.
+func (d *DiskIO) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	pattern := defaultDiskIOPattern
+	if d.Pattern != nil {
+		pattern = *d.Pattern
+	}
+	var err error
+	d.patternRe, err = regexp.Compile(pattern)
+	if err != nil {
+		d.print(tx, err, c)
+		return
+	}
+
+	if d.Warning == 0 {
+		d.Warning = 50
+	}
+	if d.Critical == 0 {
+		d.Critical = 90
+	}
+	if d.Interval <= 0 {
+		d.Interval = 5 * time.Second
+	}
+
+	f, err := os.Open("/proc/diskstats")
+	if err != nil {
+		d.print(tx, err, c)
+		return
+	}
+
+	ready := make(chan struct{}, 1)
+	defer func() {
+		f.Close()
+		close(ready)
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	var prev map[string]diskStats
+	var prevTime time.Time
+
+	for {
+		select {
+		// no click support for diskio
+		case <-rx:
+		case <-ready:
+			go func() {
+				time.Sleep(d.Interval)
+				ready <- struct{}{}
+			}()
+
+			data, err := io.ReadAll(f)
+			if err != nil {
+				d.print(tx, err, c)
+				continue
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				d.print(tx, err, c)
+				continue
+			}
+
+			now := time.Now()
+			cur, err := parseDiskstats(data)
+			if err != nil {
+				d.print(tx, err, c)
+				continue
+			}
+
+			// Rates can only be computed once there are two samples.
+			if prev == nil {
+				prev, prevTime = cur, now
+				continue
+			}
+
+			d.rates = d.rates[:0]
+			devices := maps.Keys(cur)
+			slices.Sort(devices)
+			for _, device := range devices {
+				if !d.patternRe.MatchString(device) {
+					continue
+				}
+				prevStats, ok := prev[device]
+				if !ok {
+					prevStats = cur[device]
+				}
+				d.rates = append(d.rates, newDiskRate(device, prevStats, cur[device], now.Sub(prevTime)))
+			}
+			prev, prevTime = cur, now
+
+			d.print(tx, nil, c)
+		}
+	}
+}
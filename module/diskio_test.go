@@ -0,0 +1,108 @@
+package module
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParseDiskstats(t *testing.T) {
+	data := `   7       0 loop0 46 0 2162 9 0 0 0 0 0 20 9 0 0 0 0 0 0
+ 259       0 nvme0n1 283454 94188 23345930 53513 713612 381522 48632146 1071342 0 408416 1171209 0 0 0 0 44118 46353
+ 259       1 nvme0n1p1 245 1015 12654 38 2 0 2 1 0 72 39 0 0 0 0 0 0
+   8       0 sda 1000 0 2000 300 4000 0 5000 600 0 700 900
+ 253       0 dm-0 12
+`
+
+	tt := []struct {
+		device string
+		want   diskStats
+	}{
+		{device: "loop0", want: diskStats{sectorsRead: 2162, ioTicks: 20}},
+		{device: "nvme0n1", want: diskStats{sectorsRead: 23345930, sectorsWritten: 48632146, ioTicks: 408416}},
+		{device: "nvme0n1p1", want: diskStats{sectorsRead: 12654, sectorsWritten: 2, ioTicks: 72}},
+		{device: "sda", want: diskStats{sectorsRead: 2000, sectorsWritten: 5000, ioTicks: 700}},
+	}
+
+	stats, err := parseDiskstats([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != len(tt) {
+		t.Fatalf("got %d devices, wanted %d\n", len(stats), len(tt))
+	}
+	for _, tc := range tt {
+		if got := stats[tc.device]; got != tc.want {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.device, got, tc.want)
+		}
+	}
+
+	if _, err := parseDiskstats([]byte(" 8 0 sda 1000 0 abc 300 4000 0 5000 600 0 700 900\n")); err == nil {
+		t.Fatalf("invalid counter: got no error\n")
+	}
+}
+
+func TestNewDiskRate(t *testing.T) {
+	tt := []struct {
+		name    string
+		prev    diskStats
+		cur     diskStats
+		elapsed time.Duration
+		want    diskRate
+	}{
+		{
+			name:    "idle",
+			prev:    diskStats{sectorsRead: 100, sectorsWritten: 100, ioTicks: 100},
+			cur:     diskStats{sectorsRead: 100, sectorsWritten: 100, ioTicks: 100},
+			elapsed: 5 * time.Second,
+			want:    diskRate{device: "sda"},
+		},
+		{
+			name:    "sectors to bytes",
+			prev:    diskStats{sectorsRead: 0, sectorsWritten: 0, ioTicks: 0},
+			cur:     diskStats{sectorsRead: 2048, sectorsWritten: 4096, ioTicks: 1000},
+			elapsed: 4 * time.Second,
+			want:    diskRate{device: "sda", readPerSec: 2048 * 512 / 4, writePerSec: 4096 * 512 / 4, utilization: 25},
+		},
+		{
+			name:    "utilization capped",
+			prev:    diskStats{ioTicks: 0},
+			cur:     diskStats{ioTicks: 6000},
+			elapsed: 5 * time.Second,
+			want:    diskRate{device: "sda", utilization: 100},
+		},
+		{
+			name:    "counter wraparound",
+			prev:    diskStats{sectorsRead: 1<<32 - 10, sectorsWritten: 500, ioTicks: 1<<32 - 1},
+			cur:     diskStats{sectorsRead: 20, sectorsWritten: 1524, ioTicks: 5},
+			elapsed: 2 * time.Second,
+			want:    diskRate{device: "sda", writePerSec: 1024 * 512 / 2},
+		},
+	}
+
+	for _, tc := range tt {
+		if got := newDiskRate("sda", tc.prev, tc.cur, tc.elapsed); got != tc.want {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDefaultDiskIOPattern(t *testing.T) {
+	re := regexp.MustCompile(defaultDiskIOPattern)
+	for device, want := range map[string]bool{
+		"sda":       true,
+		"sdab":      true,
+		"vda":       true,
+		"nvme0n1":   true,
+		"mmcblk0":   true,
+		"sda1":      false,
+		"nvme0n1p1": false,
+		"mmcblk0p1": false,
+		"loop0":     false,
+		"dm-0":      false,
+	} {
+		if got := re.MatchString(device); got != want {
+			t.Fatalf("%s: got %t, wanted %t\n", device, got, want)
+		}
+	}
+}
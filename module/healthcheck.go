@@ -0,0 +1,301 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+var (
+	errHealthcheckNoChecks  = errors.New("no checks configured")
+	errHealthcheckBodyMatch = errors.New("body doesn't match")
+)
+
+// ServiceCheck is a check of the Healthcheck module. Exactly one of TCP, HTTP
+// and Unix is set, except that HTTP can be sent over a Unix socket.
+type ServiceCheck struct {
+	// The name shown in blocks. Defaults to the address, URL or socket path.
+	Name string `mapstructure:"name"`
+	// An address to connect to, for example "localhost:5432".
+	TCP string `mapstructure:"tcp"`
+	// A URL to send a GET request to, for example
+	// "http://localhost:8080/healthz".
+	HTTP string `mapstructure:"http"`
+	// The expected status of the response. Defaults to any 2xx status.
+	Status int `mapstructure:"status"`
+	// A regexp the body of the response has to match.
+	Body string `mapstructure:"body"`
+	// The path of a Unix socket to connect to, or to send the HTTP request
+	// over, for example "/run/docker.sock".
+	Unix string `mapstructure:"unix"`
+
+	bodyRe *regexp.Regexp
+	client *http.Client
+}
+
+// init validates the check, the index-th of the module, and sets its
+// defaults.
+func (s *ServiceCheck) init(index int, timeout time.Duration) error {
+	if s.Name == "" {
+		for _, name := range []string{s.HTTP, s.TCP, s.Unix} {
+			if name != "" {
+				s.Name = name
+				break
+			}
+		}
+	}
+
+	switch {
+	case s.HTTP != "" && s.TCP == "":
+		s.client = newHTTPClient(s.Unix, timeout)
+	case s.TCP != "" && s.HTTP == "" && s.Unix == "":
+	case s.Unix != "" && s.TCP == "" && s.HTTP == "":
+	default:
+		if s.Name == "" {
+			return fmt.Errorf("check #%d needs exactly one of tcp, http and unix", index+1)
+		}
+		return fmt.Errorf("check %q needs exactly one of tcp, http and unix", s.Name)
+	}
+
+	if s.Body != "" {
+		var err error
+		s.bodyRe, err = regexp.Compile(s.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check returns nil if the service is healthy.
+func (s *ServiceCheck) check(timeout time.Duration) error {
+	if s.client == nil {
+		network, address := "tcp", s.TCP
+		if s.Unix != "" {
+			network, address = "unix", s.Unix
+		}
+		conn, err := net.DialTimeout(network, address, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	resp, err := s.client.Get(s.HTTP)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if s.Status != 0 && resp.StatusCode != s.Status {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if s.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if s.bodyRe != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			return err
+		}
+		if !s.bodyRe.Match(body) {
+			return errHealthcheckBodyMatch
+		}
+	}
+
+	return nil
+}
+
+// Healthcheck provides the health of services, checked by connecting to TCP
+// addresses or Unix sockets or by sending HTTP requests. Clicking the
+// aggregate block cycles through the failing checks.
+type Healthcheck struct {
+	Checks []ServiceCheck `mapstructure:"checks"`
+	// Whether to show a single block with the number of healthy checks
+	// instead of a block per check.
+	Aggregate bool `mapstructure:"aggregate"`
+	// How long to wait for each check. Defaults to 5 seconds.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// How often to run the checks. Defaults to 30 seconds.
+	Interval time.Duration `mapstructure:"interval"`
+
+	results []error
+	checked bool
+	// the failing check shown in the aggregate block
+	selected int
+}
+
+// checkAll runs all checks concurrently.
+func (h *Healthcheck) checkAll() []error {
+	results := make([]error, len(h.Checks))
+
+	var wg sync.WaitGroup
+	for i := range h.Checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = h.Checks[i].check(h.Timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// failing returns the indexes of the failing checks.
+func (h *Healthcheck) failing() []int {
+	failing := []int{}
+	for i, err := range h.results {
+		if err != nil {
+			failing = append(failing, i)
+		}
+	}
+	return failing
+}
+
+// cycle selects the next or previous failing check.
+func (h *Healthcheck) cycle(direction int) {
+	failing := h.failing()
+	if len(failing) == 0 {
+		return
+	}
+	h.selected = ((h.selected+direction)%len(failing) + len(failing)) % len(failing)
+}
+
+func (h *Healthcheck) print(tx chan []i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:     "healthcheck",
+			Instance: "healthcheck",
+			FullText: fmt.Sprintf("HEALTH: %s", err),
+			Color:    c.Red(),
+		}}
+		return
+	}
+	if !h.checked {
+		return
+	}
+
+	if h.Aggregate {
+		failing := h.failing()
+		up := len(h.Checks) - len(failing)
+
+		short := fmt.Sprintf("HEALTH: %d/%d up", up, len(h.Checks))
+		text := short
+		color := c.Normal()
+		switch {
+		case up == 0:
+			color = c.Red()
+		case len(failing) > 0:
+			color = c.Yellow()
+		}
+		if len(failing) > 0 {
+			if h.selected >= len(failing) {
+				h.selected = 0
+			}
+			idx := failing[h.selected]
+			text += fmt.Sprintf(" (%s: %s)", h.Checks[idx].Name, h.results[idx])
+		}
+
+		tx <- []i3.Block{{
+			Name:      "healthcheck",
+			Instance:  "healthcheck",
+			FullText:  text,
+			ShortText: short,
+			Color:     color,
+			Urgent:    up == 0,
+		}}
+		return
+	}
+
+	blocks := []i3.Block{}
+	for i, check := range h.Checks {
+		block := i3.Block{
+			Name:      "healthcheck",
+			Instance:  check.Name,
+			FullText:  fmt.Sprintf("%s: up", check.Name),
+			ShortText: fmt.Sprintf("%s: up", check.Name),
+			Color:     c.Normal(),
+		}
+		if err := h.results[i]; err != nil {
+			block.FullText = fmt.Sprintf("%s: %s", check.Name, err)
+			block.ShortText = fmt.Sprintf("%s: down", check.Name)
+			block.Color = c.Red()
+		}
+		blocks = append(blocks, block)
+	}
+	tx <- blocks
+}
+
+// Run implements Module.
+func (h *Healthcheck) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if len(h.Checks) == 0 {
+		h.print(tx, errHealthcheckNoChecks, c)
+		return
+	}
+	if h.Timeout <= 0 {
+		h.Timeout = 5 * time.Second
+	}
+	if h.Interval <= 0 {
+		h.Interval = 30 * time.Second
+	}
+
+	for i := range h.Checks {
+		if err := h.Checks[i].init(i, h.Timeout); err != nil {
+			h.print(tx, err, c)
+			return
+		}
+	}
+
+	ready := make(chan struct{}, 1)
+	results := make(chan []error)
+	defer func() {
+		close(ready)
+		close(results)
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	clicks := &i3.Dispatcher{}
+	clicks.HandleButtons(func(i3.ClickEvent) {
+		h.cycle(1)
+		h.print(tx, nil, c)
+	}, i3.LeftClick, i3.ScrollDown)
+	clicks.HandleButtons(func(i3.ClickEvent) {
+		h.cycle(-1)
+		h.print(tx, nil, c)
+	}, i3.RightClick, i3.ScrollUp)
+
+	for {
+		select {
+		case click := <-rx:
+			if h.Aggregate {
+				clicks.Dispatch(click)
+			}
+		case <-ready:
+			// Checks can take up to the timeout, so they are run in the
+			// background to keep handling clicks.
+			go func() {
+				results <- h.checkAll()
+				time.Sleep(h.Interval)
+				ready <- struct{}{}
+			}()
+		case checked := <-results:
+			h.results = checked
+			h.checked = true
+			h.print(tx, nil, c)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package module
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+func TestServiceCheck(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	socket := filepath.Join(t.TempDir(), "service.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unixServer := &http.Server{Handler: handler}
+	go unixServer.Serve(ln)
+	defer unixServer.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	tt := []struct {
+		name    string
+		check   ServiceCheck
+		healthy bool
+	}{
+		{
+			name:    "tcp up",
+			check:   ServiceCheck{TCP: server.Listener.Addr().String()},
+			healthy: true,
+		},
+		{
+			name:  "tcp down",
+			check: ServiceCheck{TCP: closedAddr},
+		},
+		{
+			name:    "unix socket",
+			check:   ServiceCheck{Unix: socket},
+			healthy: true,
+		},
+		{
+			name:  "missing unix socket",
+			check: ServiceCheck{Unix: filepath.Join(t.TempDir(), "missing.sock")},
+		},
+		{
+			name:    "http ok",
+			check:   ServiceCheck{HTTP: server.URL + "/healthz"},
+			healthy: true,
+		},
+		{
+			name:  "http not found",
+			check: ServiceCheck{HTTP: server.URL + "/missing"},
+		},
+		{
+			name:    "http expected status",
+			check:   ServiceCheck{HTTP: server.URL + "/missing", Status: http.StatusNotFound},
+			healthy: true,
+		},
+		{
+			name:  "http unexpected status",
+			check: ServiceCheck{HTTP: server.URL + "/created", Status: http.StatusOK},
+		},
+		{
+			name:    "http body match",
+			check:   ServiceCheck{HTTP: server.URL + "/healthz", Body: `"status":\s*"ok"`},
+			healthy: true,
+		},
+		{
+			name:  "http body mismatch",
+			check: ServiceCheck{HTTP: server.URL + "/healthz", Body: `"status":\s*"degraded"`},
+		},
+		{
+			name:    "http over unix socket",
+			check:   ServiceCheck{HTTP: "http://localhost/healthz", Unix: socket},
+			healthy: true,
+		},
+	}
+
+	for _, tc := range tt {
+		if err := tc.check.init(0, time.Second); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		err := tc.check.check(time.Second)
+		if (err == nil) != tc.healthy {
+			t.Fatalf("%s: got %v, wanted healthy %t\n", tc.name, err, tc.healthy)
+		}
+	}
+}
+
+func TestServiceCheckInit(t *testing.T) {
+	tt := []struct {
+		name    string
+		check   ServiceCheck
+		index   int
+		wantErr string
+	}{
+		{
+			name:    "nothing configured",
+			index:   1,
+			wantErr: "check #2 needs exactly one of tcp, http and unix",
+		},
+		{
+			name:    "named",
+			check:   ServiceCheck{Name: "db"},
+			wantErr: `check "db" needs exactly one of tcp, http and unix`,
+		},
+		{
+			name:    "tcp and unix",
+			check:   ServiceCheck{TCP: "localhost:5432", Unix: "/run/postgresql/.s.PGSQL.5432"},
+			wantErr: `check "localhost:5432" needs exactly one of tcp, http and unix`,
+		},
+		{
+			name:    "tcp and http",
+			check:   ServiceCheck{TCP: "localhost:8080", HTTP: "http://localhost:8080"},
+			wantErr: `check "http://localhost:8080" needs exactly one of tcp, http and unix`,
+		},
+		{
+			name:    "invalid body",
+			check:   ServiceCheck{HTTP: "http://localhost:8080", Body: "("},
+			wantErr: "error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	for _, tc := range tt {
+		err := tc.check.init(tc.index, time.Second)
+		if err == nil || err.Error() != tc.wantErr {
+			t.Fatalf("%s: got %v, wanted %s\n", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestHealthcheckCycle(t *testing.T) {
+	c := col.Color{}
+	h := &Healthcheck{
+		Checks:    []ServiceCheck{{Name: "db"}, {Name: "api"}, {Name: "cache"}, {Name: "queue"}},
+		Aggregate: true,
+		results:   []error{nil, errHealthcheckBodyMatch, nil, errHealthcheckNoChecks},
+		checked:   true,
+	}
+
+	tt := []struct {
+		direction int
+		want      string
+	}{
+		{direction: 0, want: "HEALTH: 2/4 up (api: body doesn't match)"},
+		{direction: 1, want: "HEALTH: 2/4 up (queue: no checks configured)"},
+		{direction: 1, want: "HEALTH: 2/4 up (api: body doesn't match)"},
+		{direction: -1, want: "HEALTH: 2/4 up (queue: no checks configured)"},
+	}
+
+	tx := make(chan []i3.Block, 1)
+	for i, tc := range tt {
+		h.cycle(tc.direction)
+		h.print(tx, nil, c)
+		block := (<-tx)[0]
+		if block.FullText != tc.want {
+			t.Fatalf("click %d: got %q, wanted %q\n", i, block.FullText, tc.want)
+		}
+		if block.Color != c.Yellow() {
+			t.Fatalf("click %d: got color %q, wanted %q\n", i, block.Color, c.Yellow())
+		}
+	}
+}
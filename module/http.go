@@ -0,0 +1,314 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/itchyny/gojq"
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	errHTTPNoURL    = errors.New("no url configured")
+	errHTTPNoValues = errors.New("no values configured")
+)
+
+// maxBodySize is the most of a response body that is read.
+const maxBodySize = 1 << 20
+
+// newHTTPClient returns a client with the timeout, which connects to the Unix
+// socket at socketPath instead of the URL's host if it isn't empty.
+func newHTTPClient(socketPath string, timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if socketPath != "" {
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		}
+	}
+	return client
+}
+
+// compileJQ parses and compiles a jq expression.
+func compileJQ(expr string) (*gojq.Code, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", expr, err)
+	}
+	return gojq.Compile(query)
+}
+
+// runJQ returns the result of running a compiled jq expression on a JSON
+// document. Expressions with several results return them as a list, and
+// expressions without any return nil.
+func runJQ(code *gojq.Code, doc any) (any, error) {
+	results := []any{}
+	iter := code.Run(doc)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		results = append(results, v)
+	}
+
+	switch len(results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return results[0], nil
+	default:
+		return results, nil
+	}
+}
+
+// truthy returns whether a jq result is true, following jq in treating
+// everything but null and false as true.
+func truthy(v any) bool {
+	return v != nil && v != false
+}
+
+// HTTP provides values from a JSON API, such as the status of a CI runner.
+// The values are extracted from the response with jq expressions and shown
+// through a format.
+type HTTP struct {
+	// The URL to send GET requests to.
+	URL string `mapstructure:"url"`
+	// Headers of the requests, for example an Authorization header.
+	Headers map[string]string `mapstructure:"headers"`
+	// The path of a Unix socket to send the requests over, in which case the
+	// URL's host is only used for the Host header.
+	Unix string `mapstructure:"unix"`
+	// jq expressions extracting values from the response by name, for
+	// example "status: .jobs[0].status".
+	Values map[string]string `mapstructure:"values"`
+	// A text/template for the block using the values by name, for example
+	// "CI: {{.status}}". Defaults to the values in order of their names.
+	Format string `mapstructure:"format"`
+	// The text shown for values whose expression has no result or returns
+	// null, for example "-". Defaults to nothing.
+	Placeholder string `mapstructure:"placeholder"`
+	// jq expressions on the response that color the block yellow and red
+	// when they are true, for example '.jobs[0].status == "failed"'.
+	Warning  string `mapstructure:"warning"`
+	Critical string `mapstructure:"critical"`
+	// How long to wait for a response. Defaults to 10 seconds.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// How often to send requests. Defaults to 1 minute.
+	Interval time.Duration `mapstructure:"interval"`
+
+	client   *http.Client
+	names    []string
+	values   map[string]*gojq.Code
+	warning  *gojq.Code
+	critical *gojq.Code
+	format   *template.Template
+}
+
+func (h *HTTP) init() error {
+	if h.URL == "" {
+		return errHTTPNoURL
+	}
+	if len(h.Values) == 0 {
+		return errHTTPNoValues
+	}
+
+	h.client = newHTTPClient(h.Unix, h.Timeout)
+
+	h.names = maps.Keys(h.Values)
+	slices.Sort(h.names)
+	h.values = map[string]*gojq.Code{}
+	for name, expr := range h.Values {
+		code, err := compileJQ(expr)
+		if err != nil {
+			return err
+		}
+		h.values[name] = code
+	}
+
+	var err error
+	if h.Warning != "" {
+		if h.warning, err = compileJQ(h.Warning); err != nil {
+			return err
+		}
+	}
+	if h.Critical != "" {
+		if h.critical, err = compileJQ(h.Critical); err != nil {
+			return err
+		}
+	}
+
+	if h.Format != "" {
+		if h.format, err = template.New("http").Parse(h.Format); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetch requests the URL and decodes the JSON response.
+func (h *HTTP) fetch() (any, error) {
+	req, err := http.NewRequest(http.MethodGet, h.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range h.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var doc any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// block returns the block for a JSON document.
+func (h *HTTP) block(doc any, c col.Color) (i3.Block, error) {
+	values := map[string]any{}
+	for name, code := range h.values {
+		v, err := runJQ(code, doc)
+		if err != nil {
+			return i3.Block{}, fmt.Errorf("%s: %w", name, err)
+		}
+		if v == nil {
+			v = h.Placeholder
+		}
+		values[name] = v
+	}
+
+	var text string
+	if h.format != nil {
+		var buf strings.Builder
+		if err := h.format.Execute(&buf, values); err != nil {
+			return i3.Block{}, err
+		}
+		text = buf.String()
+	} else {
+		parts := []string{}
+		for _, name := range h.names {
+			parts = append(parts, fmt.Sprint(values[name]))
+		}
+		text = strings.Join(parts, " ")
+	}
+
+	block := i3.Block{
+		Name:     "http",
+		Instance: "http",
+		FullText: text,
+		Color:    c.Normal(),
+	}
+	for _, check := range []struct {
+		code  *gojq.Code
+		color string
+	}{{h.critical, c.Red()}, {h.warning, c.Yellow()}} {
+		if check.code == nil {
+			continue
+		}
+		v, err := runJQ(check.code, doc)
+		if err != nil {
+			return i3.Block{}, err
+		}
+		if truthy(v) {
+			block.Color = check.color
+			block.Urgent = check.code == h.critical
+			break
+		}
+	}
+
+	return block, nil
+}
+
+func (h *HTTP) print(tx chan []i3.Block, err error, c col.Color) {
+	tx <- []i3.Block{{
+		Name:      "http",
+		Instance:  "http",
+		FullText:  fmt.Sprintf("HTTP: %s", err),
+		ShortText: "HTTP: error",
+		Color:     c.Red(),
+	}}
+}
+
+// Run implements Module.
+func (h *HTTP) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if h.Timeout <= 0 {
+		h.Timeout = 10 * time.Second
+	}
+	if h.Interval <= 0 {
+		h.Interval = time.Minute
+	}
+
+	if err := h.init(); err != nil {
+		h.print(tx, err, c)
+		return
+	}
+
+	ready := make(chan struct{}, 1)
+	type response struct {
+		doc any
+		err error
+	}
+	responses := make(chan response)
+	defer func() {
+		close(ready)
+		close(responses)
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	for {
+		select {
+		// no click support for http
+		case <-rx:
+		case <-ready:
+			// Requests can take up to the timeout, so they are sent in the
+			// background to keep handling clicks.
+			go func() {
+				doc, err := h.fetch()
+				responses <- response{doc: doc, err: err}
+				time.Sleep(h.Interval)
+				ready <- struct{}{}
+			}()
+		case resp := <-responses:
+			if resp.err != nil {
+				h.print(tx, resp.err, c)
+				continue
+			}
+			block, err := h.block(resp.doc, c)
+			if err != nil {
+				h.print(tx, err, c)
+				continue
+			}
+			tx <- []i3.Block{block}
+		}
+	}
+}
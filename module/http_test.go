@@ -0,0 +1,193 @@
+package module
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+)
+
+const ciResponse = `{"jobs": [
+	{"name": "build", "status": "success", "duration": 42},
+	{"name": "test", "status": "failed", "duration": 120}
+]}`
+
+func TestHTTPBlock(t *testing.T) {
+	c := col.Color{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(ciResponse))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	socket := filepath.Join(t.TempDir(), "ci.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unixServer := &http.Server{Handler: handler}
+	go unixServer.Serve(ln)
+	defer unixServer.Close()
+
+	headers := map[string]string{"Authorization": "Bearer secret"}
+
+	tt := []struct {
+		name      string
+		h         HTTP
+		wantText  string
+		wantColor string
+		wantErr   bool
+	}{
+		{
+			name: "default format",
+			h: HTTP{
+				URL:     server.URL,
+				Headers: headers,
+				Values:  map[string]string{"b": ".jobs[0].name", "a": ".jobs | length"},
+			},
+			wantText:  "2 build",
+			wantColor: c.Normal(),
+		},
+		{
+			name: "format",
+			h: HTTP{
+				URL:     server.URL,
+				Headers: headers,
+				Values: map[string]string{
+					"status":   ".jobs[-1].status",
+					"duration": "[.jobs[].duration] | add",
+				},
+				Format: "CI: {{.status}} in {{.duration}}s",
+			},
+			wantText:  "CI: failed in 162s",
+			wantColor: c.Normal(),
+		},
+		{
+			name: "multiple results",
+			h: HTTP{
+				URL:     server.URL,
+				Headers: headers,
+				Values:  map[string]string{"statuses": ".jobs[].status"},
+			},
+			wantText:  "[success failed]",
+			wantColor: c.Normal(),
+		},
+		{
+			name: "no result",
+			h: HTTP{
+				URL:     server.URL,
+				Headers: headers,
+				Values: map[string]string{
+					"a": ".jobs[0].name",
+					"b": `.jobs[] | select(.status == "running") | .name`,
+				},
+			},
+			wantText:  "build ",
+			wantColor: c.Normal(),
+		},
+		{
+			name: "placeholder",
+			h: HTTP{
+				URL:     server.URL,
+				Headers: headers,
+				Values: map[string]string{
+					"running": `.jobs[] | select(.status == "running") | .name`,
+					"queued":  ".queued",
+				},
+				Format:      "CI: {{.running}} {{.queued}}",
+				Placeholder: "-",
+			},
+			wantText:  "CI: - -",
+			wantColor: c.Normal(),
+		},
+		{
+			name: "critical",
+			h: HTTP{
+				URL:      server.URL,
+				Headers:  headers,
+				Values:   map[string]string{"status": ".jobs[-1].status"},
+				Warning:  `any(.jobs[]; .duration > 60)`,
+				Critical: `any(.jobs[]; .status == "failed")`,
+			},
+			wantText:  "failed",
+			wantColor: c.Red(),
+		},
+		{
+			name: "warning",
+			h: HTTP{
+				URL:      server.URL,
+				Headers:  headers,
+				Values:   map[string]string{"status": ".jobs[0].status"},
+				Warning:  `any(.jobs[]; .duration > 60)`,
+				Critical: `.jobs[0].status == "failed"`,
+			},
+			wantText:  "success",
+			wantColor: c.Yellow(),
+		},
+		{
+			name: "unix socket",
+			h: HTTP{
+				URL:     "http://ci/api",
+				Unix:    socket,
+				Headers: headers,
+				Values:  map[string]string{"name": ".jobs[1].name"},
+			},
+			wantText:  "test",
+			wantColor: c.Normal(),
+		},
+		{
+			name: "unauthorized",
+			h: HTTP{
+				URL:    server.URL,
+				Values: map[string]string{"status": ".jobs[0].status"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid expression",
+			h: HTTP{
+				URL:    server.URL,
+				Values: map[string]string{"status": ".jobs[0"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc.h.Timeout = time.Second
+		err := tc.h.init()
+		var doc any
+		if err == nil {
+			doc, err = tc.h.fetch()
+		}
+		if err != nil {
+			if !tc.wantErr {
+				t.Fatalf("%s: %v\n", tc.name, err)
+			}
+			continue
+		}
+		if tc.wantErr {
+			t.Fatalf("%s: got no error, wanted one\n", tc.name)
+		}
+
+		block, err := tc.h.block(doc, c)
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if block.FullText != tc.wantText {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, block.FullText, tc.wantText)
+		}
+		if block.Color != tc.wantColor {
+			t.Fatalf("%s: got color %q, wanted %q\n", tc.name, block.Color, tc.wantColor)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+var errLoadInvalid = errors.New("invalid /proc/loadavg")
+
+// Load provides the system load average and task counts. Only works on Linux.
+type Load struct {
+	// Whether to divide the load averages by the number of CPUs.
+	Normalize bool `mapstructure:"normalize"`
+	// Load per CPU at which the block is colored yellow and red. Default to
+	// 0.7 and 1.
+	Warning  float64 `mapstructure:"warning"`
+	Critical float64 `mapstructure:"critical"`
+	// How often to read /proc/loadavg. Defaults to 5 seconds.
+	Interval time.Duration `mapstructure:"interval"`
+
+	load1, load5, load15 float64
+	running, total       int
+	detailed             bool
+	// the number of CPUs the load is divided by
+	cpus float64
+}
+
+func (l *Load) print(tx chan []i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:     "load",
+			Instance: "load",
+			FullText: fmt.Sprintf("LOAD: %s", err),
+			Color:    c.Red(),
+			Urgent:   true,
+		}}
+		return
+	}
+
+	cpus := l.cpus
+	load1, load5, load15 := l.load1, l.load5, l.load15
+	if l.Normalize {
+		load1, load5, load15 = load1/cpus, load5/cpus, load15/cpus
+	}
+
+	urgent := false
+	color := c.Normal()
+	switch perCPU := l.load1 / cpus; {
+	case perCPU > l.Critical:
+		color = c.Red()
+		urgent = true
+	case perCPU > l.Warning:
+		color = c.Yellow()
+	}
+
+	short := fmt.Sprintf("LOAD: %.2f", load1)
+	text := short
+	if l.detailed {
+		text = fmt.Sprintf("LOAD: %.2f %.2f %.2f %d/%d", load1, load5, load15, l.running, l.total)
+	}
+
+	tx <- []i3.Block{{
+		Name:      "load",
+		Instance:  "load",
+		FullText:  text,
+		ShortText: short,
+		Color:     color,
+		Urgent:    urgent,
+	}}
+}
+
+// parse reads the load averages and task counts from the contents of
+// /proc/loadavg, for example "0.52 0.58 0.59 2/1234 5678".
+func (l *Load) parse(data []byte) error {
+	fields := strings.Fields(string(data))
+	if len(fields) < 4 {
+		return errLoadInvalid
+	}
+
+	loads := make([]float64, 3)
+	for i := range loads {
+		load, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return err
+		}
+		loads[i] = load
+	}
+
+	running, total, ok := strings.Cut(fields[3], "/")
+	if !ok {
+		return errLoadInvalid
+	}
+	runningInt, err := strconv.Atoi(running)
+	if err != nil {
+		return err
+	}
+	totalInt, err := strconv.Atoi(total)
+	if err != nil {
+		return err
+	}
+
+	l.load1, l.load5, l.load15 = loads[0], loads[1], loads[2]
+	l.running, l.total = runningInt, totalInt
+
+	return nil
+}
+
+// Run implements Module.
+func (l *Load) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if l.Warning == 0 {
+		l.Warning = 0.7
+	}
+	if l.Critical == 0 {
+		l.Critical = 1
+	}
+	if l.Interval <= 0 {
+		l.Interval = 5 * time.Second
+	}
+	l.cpus = float64(runtime.NumCPU())
+
+	f, err := os.Open("/proc/loadavg")
+	if err != nil {
+		l.print(tx, err, c)
+		return
+	}
+
+	ready := make(chan struct{}, 1)
+	defer func() {
+		f.Close()
+		close(ready)
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	clicks := &i3.Dispatcher{}
+	clicks.HandleButtons(func(i3.ClickEvent) {
+		l.detailed = !l.detailed
+		l.print(tx, nil, c)
+	}, i3.LeftClick, i3.RightClick, i3.ScrollUp, i3.ScrollDown)
+
+	for {
+		select {
+		case click := <-rx:
+			clicks.Dispatch(click)
+		case <-ready:
+			go func() {
+				time.Sleep(l.Interval)
+				ready <- struct{}{}
+			}()
+
+			data, err := io.ReadAll(f)
+			if err != nil {
+				l.print(tx, err, c)
+				continue
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				l.print(tx, err, c)
+				continue
+			}
+
+			if err := l.parse(data); err != nil {
+				l.print(tx, err, c)
+				continue
+			}
+
+			l.print(tx, nil, c)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package module
+
+import (
+	"testing"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+func TestLoadParse(t *testing.T) {
+	tt := []struct {
+		name                 string
+		data                 string
+		load1, load5, load15 float64
+		running, total       int
+		wantErr              bool
+	}{
+		{
+			name:  "loadavg",
+			data:  "0.52 0.58 0.59 2/1234 5678\n",
+			load1: 0.52, load5: 0.58, load15: 0.59,
+			running: 2, total: 1234,
+		},
+		{
+			name:    "missing fields",
+			data:    "0.52 0.58 0.59\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid load",
+			data:    "abc 0.58 0.59 2/1234 5678\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid tasks",
+			data:    "0.52 0.58 0.59 1234 5678\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		l := &Load{}
+		err := l.parse([]byte(tc.data))
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, wanted error %v\n", tc.name, err, tc.wantErr)
+		}
+		if tc.wantErr {
+			continue
+		}
+		if l.load1 != tc.load1 || l.load5 != tc.load5 || l.load15 != tc.load15 {
+			t.Fatalf("%s: got %v %v %v, wanted %v %v %v\n", tc.name, l.load1, l.load5, l.load15, tc.load1, tc.load5, tc.load15)
+		}
+		if l.running != tc.running || l.total != tc.total {
+			t.Fatalf("%s: got %d/%d, wanted %d/%d\n", tc.name, l.running, l.total, tc.running, tc.total)
+		}
+	}
+}
+
+func TestLoadPrint(t *testing.T) {
+	c := col.Color{}
+	tt := []struct {
+		name       string
+		load       Load
+		wantText   string
+		wantColor  string
+		wantUrgent bool
+	}{
+		{
+			name:      "low",
+			load:      Load{load1: 1, load5: 2, load15: 3},
+			wantText:  "LOAD: 1.00",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "warning per cpu",
+			load:      Load{load1: 3},
+			wantText:  "LOAD: 3.00",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:       "critical per cpu",
+			load:       Load{load1: 5},
+			wantText:   "LOAD: 5.00",
+			wantColor:  c.Red(),
+			wantUrgent: true,
+		},
+		{
+			name:      "normalized",
+			load:      Load{Normalize: true, load1: 3, load5: 2, load15: 1},
+			wantText:  "LOAD: 0.75",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "detailed",
+			load:      Load{Normalize: true, detailed: true, load1: 2, load5: 1, load15: 0.5, running: 3, total: 400},
+			wantText:  "LOAD: 0.50 0.25 0.12 3/400",
+			wantColor: c.Normal(),
+		},
+	}
+
+	for _, tc := range tt {
+		l := tc.load
+		l.Warning, l.Critical, l.cpus = 0.7, 1, 4
+
+		tx := make(chan []i3.Block, 1)
+		l.print(tx, nil, c)
+		block := (<-tx)[0]
+		if block.FullText != tc.wantText {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, block.FullText, tc.wantText)
+		}
+		if block.Color != tc.wantColor || block.Urgent != tc.wantUrgent {
+			t.Fatalf("%s: got color %q urgent %t, wanted %q %t\n", tc.name, block.Color, block.Urgent, tc.wantColor, tc.wantUrgent)
+		}
+	}
+}
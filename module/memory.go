@@ -78,19 +78,21 @@ func (m *Memory) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 
 	m.currentLabel = "MEM"
 
+	clicks := &i3.Dispatcher{}
+	clicks.HandleButtons(func(i3.ClickEvent) {
+		if m.currentLabel == "SWAP" {
+			m.currentLabel = "MEM"
+		} else {
+			m.currentLabel = "SWAP"
+		}
+		m.print(tx, nil, c)
+	}, i3.LeftClick, i3.RightClick, i3.ScrollUp, i3.ScrollDown)
+
 outer:
 	for {
 		select {
 		case click := <-rx:
-			switch true {
-			case click.Button == i3.LeftClick || click.Button == i3.RightClick:
-				if m.currentLabel == "SWAP" {
-					m.currentLabel = "MEM"
-				} else {
-					m.currentLabel = "SWAP"
-				}
-				m.print(tx, nil, c)
-			}
+			clicks.Dispatch(click)
 		case <-ready:
 			var memTotal, memAvailable, swapTotal, swapFree float32
 
@@ -10,13 +10,16 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
+	"strconv"
 	"syscall"
+	"time"
 
 	col "github.com/jmbaur/gobar/color"
 	"github.com/jmbaur/gobar/config"
 	"github.com/jmbaur/gobar/i3"
+	"github.com/jmbaur/gobar/width"
 	"github.com/mitchellh/mapstructure"
-	"golang.org/x/exp/slices"
 )
 
 // Module is a thing that can print to a block on the i3bar.
@@ -24,6 +27,9 @@ type Module interface {
 	Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color)
 }
 
+// clickBuffer is the number of clicks queued for a module while it is busy.
+const clickBuffer = 8
+
 var header = i3.Header{
 	Version:     1,
 	StopSignal:  syscall.SIGUSR1,
@@ -59,12 +65,30 @@ func parseStdin(state []moduleState) {
 			log.Printf("error parsing click event: %v", err)
 		}
 
-		for i, modState := range state {
-			if modState.name == event.Name {
-				state[i].clickChan <- event
+		dispatchClick(state, event)
+		parseComma = true
+	}
+}
+
+// dispatchClick runs the click bindings of the module the click was made on
+// and passes the click on to the module, unless a binding matched and
+// overrides the module's own handling.
+func dispatchClick(state []moduleState, event i3.ClickEvent) {
+	for i, modState := range state {
+		if modState.id == event.Name {
+			event.Name = modState.name
+			if modState.onClick.Dispatch(event) && modState.onClickOverride {
+				continue
+			}
+			// Modules that have returned, such as text or one that failed
+			// to start, never read their clicks, so clicks that don't fit
+			// in the buffer are dropped rather than blocking the clicks of
+			// every other module.
+			select {
+			case state[i].clickChan <- event:
+			default:
 			}
 		}
-		parseComma = true
 	}
 }
 
@@ -81,15 +105,115 @@ func handleSignals(signals chan os.Signal, done chan<- struct{}, pause chan<- bo
 	}
 }
 
+// options are configuration values that apply to every module.
+type options struct {
+	// Whether to remember the widest rendering of each block and use it as
+	// the block's minimum width, so that the bar layout doesn't shift as the
+	// block's contents change.
+	StickyWidth *bool `mapstructure:"sticky_width"`
+	// Commands to run when the module's blocks are clicked, keyed by button
+	// and modifiers, for example "left", "shift+right" or "scroll_up".
+	OnClick map[string]string `mapstructure:"on_click"`
+	// Whether a matching on_click binding replaces the module's own click
+	// handling instead of running alongside it.
+	OnClickOverride bool `mapstructure:"on_click_override"`
+}
+
 type moduleState struct {
-	name      string
-	mod       Module
-	clickChan chan i3.ClickEvent
-	blocks    []i3.Block
+	// id uniquely identifies the module in the blocks sent to i3bar, so that
+	// click events can be routed back when the same module is configured
+	// more than once.
+	id          string
+	name        string
+	mod         Module
+	clickChan   chan i3.ClickEvent
+	blocks      []i3.Block
+	stickyWidth bool
+	widest      map[string]string
+
+	onClick         *i3.Dispatcher
+	onClickOverride bool
+}
+
+// setBlocks updates the blocks for the module, applying the widest previously
+// seen rendering of each block as its minimum width when sticky width is
+// enabled. The widest rendering is kept per instance and forgotten once the
+// module stops showing the instance.
+func (s *moduleState) setBlocks(blocks []i3.Block) {
+	if s.stickyWidth {
+		widest := make(map[string]string, len(blocks))
+		for i, block := range blocks {
+			if block.MinWidth != 0 {
+				continue
+			}
+
+			measure := width.String
+			if block.Markup == "pango" {
+				measure = width.Markup
+			}
+
+			text := block.FullText
+			if measure(block.MinWidthText) > measure(text) {
+				text = block.MinWidthText
+			}
+
+			prev, ok := s.widest[block.Instance]
+			if ok && measure(prev) > measure(text) {
+				text = prev
+			}
+			widest[block.Instance] = text
+			blocks[i].MinWidthText = text
+		}
+		s.widest = widest
+	}
+
+	for i := range blocks {
+		blocks[i].Name = s.id
+	}
+
+	s.blocks = blocks
+}
+
+// durationHook allows time.Duration fields to be configured either as a
+// number of seconds or as a duration string, such as "1m30s".
+func durationHook(_ reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+
+	switch v := data.(type) {
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		return time.ParseDuration(v)
+	default:
+		return data, nil
+	}
+}
+
+func decode(input, output any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(durationHook, byteSizeHook, rttHook),
+		Result:     output,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// moduleUpdate holds new blocks sent by the module at idx in the list of
+// running modules.
+type moduleUpdate struct {
+	idx    int
+	blocks []i3.Block
 }
 
 func decodeToState(cfg *config.Config) []moduleState {
 	state := []moduleState{}
+	occurrences := map[string]int{}
 
 	for _, maybeModAny := range cfg.Modules {
 		var mod Module
@@ -105,27 +229,72 @@ func decodeToState(cfg *config.Config) []moduleState {
 			switch name {
 			case "battery":
 				mod = &Battery{}
+			case "command":
+				mod = &Command{}
+			case "cpu":
+				mod = &CPU{}
 			case "datetime":
 				mod = &Datetime{}
+			case "disk":
+				mod = &Disk{}
+			case "diskio":
+				mod = &DiskIO{}
+			case "healthcheck":
+				mod = &Healthcheck{}
+			case "http":
+				mod = &HTTP{}
+			case "load":
+				mod = &Load{}
 			case "memory":
 				mod = &Memory{}
 			case "network":
 				mod = &Network{}
+			case "ping":
+				mod = &Ping{}
+			case "stream":
+				mod = &Stream{}
+			case "temperature":
+				mod = &Temperature{}
 			case "text":
 				mod = &Text{}
 			default:
 				log.Printf("module '%s' not found", maybeName)
 				continue
 			}
-			if err := mapstructure.Decode(maybeMod, &mod); err != nil {
+			if err := decode(maybeMod, &mod); err != nil {
 				log.Printf("failed to decode map structure: %v", err)
 				continue
 			}
+			var opts options
+			if err := decode(maybeMod, &opts); err != nil {
+				log.Printf("failed to decode module options: %v", err)
+				continue
+			}
+			stickyWidth := cfg.StickyWidth
+			if opts.StickyWidth != nil {
+				stickyWidth = *opts.StickyWidth
+			}
+			onClick, err := parseClickBindings(opts.OnClick)
+			if err != nil {
+				log.Printf("failed to decode module options: %v", err)
+				continue
+			}
+			id := name
+			if occurrences[name] > 0 {
+				id = name + "#" + strconv.Itoa(occurrences[name]+1)
+			}
+			occurrences[name]++
 			state = append(state, moduleState{
-				name:      name,
-				mod:       mod,
-				clickChan: make(chan i3.ClickEvent),
-				blocks:    []i3.Block{},
+				id:          id,
+				name:        name,
+				mod:         mod,
+				clickChan:   make(chan i3.ClickEvent, clickBuffer),
+				blocks:      []i3.Block{},
+				stickyWidth: stickyWidth,
+				widest:      map[string]string{},
+
+				onClick:         onClick,
+				onClickOverride: opts.OnClickOverride,
 			})
 		}
 	}
@@ -144,10 +313,9 @@ func Run(cfg *config.Config) error {
 	fmt.Printf("%s\n", headerData)
 
 	done := make(chan struct{}, 1)
-	blocksChan := make(chan []i3.Block)
+	updates := make(chan moduleUpdate)
 	defer func() {
 		close(done)
-		close(blocksChan)
 		for _, v := range state {
 			close(v.clickChan)
 		}
@@ -161,8 +329,14 @@ func Run(cfg *config.Config) error {
 
 	c := col.Color{Variant: cfg.ColorVariant}
 
-	for _, modState := range state {
-		go modState.mod.Run(blocksChan, modState.clickChan, c)
+	for i, modState := range state {
+		tx := make(chan []i3.Block)
+		go modState.mod.Run(tx, modState.clickChan, c)
+		go func(idx int) {
+			for blocks := range tx {
+				updates <- moduleUpdate{idx: idx, blocks: blocks}
+			}
+		}(i)
 	}
 
 	go parseStdin(state)
@@ -184,20 +358,9 @@ func Run(cfg *config.Config) error {
 				isPaused = paused
 				continue
 			}
-		case blocks := <-blocksChan:
+		case update := <-updates:
 			{
-				if len(blocks) == 0 {
-					continue
-				}
-
-				pos := slices.IndexFunc(state, func(modState moduleState) bool {
-					return modState.name == blocks[0].Name
-				})
-				if pos == -1 {
-					continue
-				}
-
-				state[pos].blocks = blocks
+				state[update.idx].setBlocks(update.blocks)
 			}
 		case <-done:
 			{
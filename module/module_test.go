@@ -0,0 +1,81 @@
+package module
+
+import (
+	"testing"
+
+	"github.com/jmbaur/gobar/i3"
+)
+
+func TestSetBlocksStickyWidth(t *testing.T) {
+	tt := []struct {
+		name   string
+		blocks []i3.Block
+		want   []i3.Block
+	}{
+		{
+			name:   "first rendering",
+			blocks: []i3.Block{{Instance: "a", FullText: "CPU: 100%"}, {Instance: "b", FullText: "x"}},
+			want:   []i3.Block{{Name: "cpu", Instance: "a", FullText: "CPU: 100%", MinWidthText: "CPU: 100%"}, {Name: "cpu", Instance: "b", FullText: "x", MinWidthText: "x"}},
+		},
+		{
+			name:   "narrower keeps widest",
+			blocks: []i3.Block{{Instance: "a", FullText: "CPU: 5%"}, {Instance: "b", FullText: "xyz"}},
+			want:   []i3.Block{{Name: "cpu", Instance: "a", FullText: "CPU: 5%", MinWidthText: "CPU: 100%"}, {Name: "cpu", Instance: "b", FullText: "xyz", MinWidthText: "xyz"}},
+		},
+		{
+			name:   "module min width text",
+			blocks: []i3.Block{{Instance: "a", FullText: "CPU: 5%", MinWidthText: "CPU: 100% (us 100%)"}},
+			want:   []i3.Block{{Name: "cpu", Instance: "a", FullText: "CPU: 5%", MinWidthText: "CPU: 100% (us 100%)"}},
+		},
+		{
+			name:   "pixel min width is kept",
+			blocks: []i3.Block{{Instance: "a", FullText: "CPU: 5%", MinWidth: 100}},
+			want:   []i3.Block{{Name: "cpu", Instance: "a", FullText: "CPU: 5%", MinWidth: 100}},
+		},
+		{
+			name:   "new instance starts over",
+			blocks: []i3.Block{{Instance: "c", FullText: "CPU: 5%"}},
+			want:   []i3.Block{{Name: "cpu", Instance: "c", FullText: "CPU: 5%", MinWidthText: "CPU: 5%"}},
+		},
+		{
+			name:   "returning instance starts over",
+			blocks: []i3.Block{{Instance: "a", FullText: "CPU: 5%"}},
+			want:   []i3.Block{{Name: "cpu", Instance: "a", FullText: "CPU: 5%", MinWidthText: "CPU: 5%"}},
+		},
+		{
+			name:   "wide characters",
+			blocks: []i3.Block{{Instance: "a", FullText: "漢字漢字"}},
+			want:   []i3.Block{{Name: "cpu", Instance: "a", FullText: "漢字漢字", MinWidthText: "漢字漢字"}},
+		},
+		{
+			name:   "narrower by display width",
+			blocks: []i3.Block{{Instance: "a", FullText: "abcdefg"}},
+			want:   []i3.Block{{Name: "cpu", Instance: "a", FullText: "abcdefg", MinWidthText: "漢字漢字"}},
+		},
+	}
+
+	// The cases run in order against the same module.
+	s := &moduleState{id: "cpu", name: "cpu", stickyWidth: true, widest: map[string]string{}}
+	for _, tc := range tt {
+		s.setBlocks(tc.blocks)
+		if len(s.blocks) != len(tc.want) {
+			t.Fatalf("%s: got %d blocks, wanted %d\n", tc.name, len(s.blocks), len(tc.want))
+		}
+		for i := range tc.want {
+			if s.blocks[i] != tc.want[i] {
+				t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, s.blocks[i], tc.want[i])
+			}
+		}
+	}
+}
+
+func TestSetBlocksWithoutStickyWidth(t *testing.T) {
+	s := &moduleState{id: "cpu#2", name: "cpu", widest: map[string]string{}}
+	s.setBlocks([]i3.Block{{Instance: "a", FullText: "CPU: 100%"}})
+	s.setBlocks([]i3.Block{{Instance: "a", FullText: "CPU: 5%"}})
+
+	want := i3.Block{Name: "cpu#2", Instance: "a", FullText: "CPU: 5%"}
+	if len(s.blocks) != 1 || s.blocks[0] != want {
+		t.Fatalf("got %+v, wanted %+v\n", s.blocks, want)
+	}
+}
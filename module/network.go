@@ -1,23 +1,32 @@
 package module
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net"
 	"net/netip"
+	"path/filepath"
 	"regexp"
-	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	col "github.com/jmbaur/gobar/color"
 	"github.com/jmbaur/gobar/i3"
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
 	"golang.org/x/exp/slices"
 	"golang.org/x/sys/unix"
 )
 
 var (
-	errNetworkInvalidPattern = errors.New("invalid interface pattern string")
-	errNetworkNoMatch        = errors.New("no matching interface")
+	errNetworkNoSelection = errors.New("no interfaces or patterns configured")
+	errNetworkNoMatch     = errors.New("no matching interface")
+	errNetworkSubClosed   = errors.New("netlink subscription closed")
 )
 
 var (
@@ -28,125 +37,937 @@ var (
 )
 
 type iface struct {
+	// whether the interface was configured by its exact name, in which case
+	// it is shown even without any addresses
+	exact    bool
 	hideIP   bool
+	detailed bool
 	link     netlink.Link
+	// all addresses of the interface, shown in the detailed view
+	addrs []netlink.Addr
+	// link speed in Mbit/s and duplex from sysfs, if the driver reports them
+	speed    int
+	duplex   string
 	ipv4     net.IP
 	ipv4Mask net.IPMask
 	ipv6     net.IP
 	ipv6Mask net.IPMask
+
+	// counters from the last sample of the link statistics
+	rxBytes uint64
+	txBytes uint64
+	sampled time.Time
+	// bytes per second between the last two samples
+	rxRate float64
+	txRate float64
+
+	// details of the connection of wireless links, nil for other links
+	wireless *wirelessInfo
+
+	// traffic during the billing period, nil until the first sample and
+	// while the counter can't be loaded or saved
+	traffic      *trafficCounter
+	trafficSaved time.Time
+	trafficErr   error
+}
+
+// networkFields are the values available to the Network module's format.
+type networkFields struct {
+	Name string
+	// The name of the network namespace the interface is in, empty unless
+	// the netns option is set.
+	Netns string
+	// The preferred address of each family, empty if the interface has none
+	// or addresses are hidden.
+	IPv4 string
+	IPv6 string
+	// All addresses of the interface, empty unless the detailed view is
+	// shown.
+	Addresses []string
+	// Formatted receive and transmit rates, for example "1.2MiB/s".
+	RX string
+	TX string
+	// Receive and transmit rates in bytes per second.
+	RXRate float64
+	TXRate float64
+	// The operational state of the link, for example "up", "down" or
+	// "unknown".
+	State string
+	// Whether the link has a carrier.
+	Carrier bool
+	// The link speed in Mbit/s and the duplex mode, if reported by the driver.
+	Speed  int
+	Duplex string
+	MTU    int
+	// The kind of link, for example "device", "wireguard", "tuntap", "vlan",
+	// "bridge" or "veth".
+	Kind string
+	// Whether the link is wireless and connected, and the SSID of the
+	// connection if known.
+	Wireless  bool
+	Connected bool
+	SSID      string
+	// The signal strength in dBm and as a percentage, and the icon for it.
+	Signal     int
+	Quality    int
+	SignalIcon string
+	// The frequency band, for example "5GHz", and the frequency in MHz.
+	Band      string
+	Frequency int
+	// The tx bitrate in Mbit/s.
+	Bitrate float64
+	// The formatted traffic during the billing period, for example
+	// "1.2GiB", the traffic in bytes and the percentage of the quota used.
+	Traffic      string
+	TrafficBytes float64
+	QuotaPercent float64
 }
 
 // Network provides IP address information for chosen network interfaces. The
-// interface can be an exact match on the interface name or a match on a name
-// regexp. Only works on Linux.
+// interfaces can be exact matches on interface names or matches on name
+// regexps, optionally excluding names and kinds of links. Interfaces matched
+// by a regexp are hidden while they have no addresses. A middle click shows or
+// hides the preferred addresses of an interface and a right click shows all of
+// its addresses. Only works on Linux.
 type Network struct {
 	Interface *string
 	Pattern   *string
+	// Exact interface names to show, in addition to Interface.
+	Interfaces []string `mapstructure:"interfaces"`
+	// Regexps of interface names to show, in addition to Pattern.
+	Include []string `mapstructure:"include"`
+	// Regexps of interface names to skip even if they match an include
+	// pattern, for example "veth.*", "docker0" or "virbr.*".
+	Exclude []string `mapstructure:"exclude"`
+	// Kinds of links to show and skip when matching patterns, for example
+	// "device", "wireguard", "tuntap", "vlan", "bridge" or "veth".
+	Kinds        []string `mapstructure:"kinds"`
+	ExcludeKinds []string `mapstructure:"exclude_kinds"`
+	// The address families to show, "ipv4" and/or "ipv6". Defaults to both.
+	Families []string `mapstructure:"families"`
+	// How the preferred address of each family is chosen.
+	AddressPolicy AddressPolicy `mapstructure:"address_policy"`
+	// Whether to only show the interfaces carrying the default IPv4 and IPv6
+	// routes with the best metric, out of the matching interfaces.
+	Primary bool `mapstructure:"primary"`
+	// Whether to show addresses with their prefix length, for example
+	// "192.168.1.2/24".
+	CIDR bool `mapstructure:"cidr"`
+	// Whether to show the receive and transmit rates of each interface.
+	Rates bool `mapstructure:"rates"`
+	// The unit of the rates, "bytes" (the default) or "bits".
+	RateUnit string `mapstructure:"rate_unit"`
+	// Receive or transmit rates in bytes per second at which an interface is
+	// colored yellow and red. Zero disables the threshold.
+	RateWarning  float64 `mapstructure:"rate_warning"`
+	RateCritical float64 `mapstructure:"rate_critical"`
+	// How often to sample the link statistics for the rates. Defaults to 5
+	// seconds.
+	Interval time.Duration `mapstructure:"interval"`
+	// How often to replace the interfaces and addresses with the kernel's
+	// current state, in case updates were missed. Defaults to 5 minutes.
+	ResyncInterval time.Duration `mapstructure:"resync_interval"`
+	// A text/template for each interface's block, using the fields of
+	// networkFields, for example "{{.Name}} {{.RX}} {{.TX}}".
+	Format string `mapstructure:"format"`
+	// The network namespace to show the interfaces of, either a name under
+	// /var/run/netns as created by "ip netns add" or a path such as
+	// "/proc/1234/ns/net". Defaults to the bar's own namespace.
+	Netns string `mapstructure:"netns"`
+	// The name of the namespace shown in blocks and used for the traffic
+	// counters. Defaults to the name for namespaces created by "ip netns
+	// add", and to "netns-" followed by the namespace's inode for other
+	// paths.
+	NetnsName string `mapstructure:"netns_name"`
+	// Whether to show the SSID and signal strength of wireless links, and
+	// their frequency band and bitrate in the detailed view.
+	Wireless bool `mapstructure:"wireless"`
+	// Signal strengths in dBm below which a wireless link is colored yellow
+	// and red. Default to -70 and -80.
+	SignalWarning  int `mapstructure:"signal_warning"`
+	SignalCritical int `mapstructure:"signal_critical"`
+	// Icons for increasing signal strengths, chosen by the signal quality.
+	// Defaults to bars of increasing height.
+	SignalIcons []string `mapstructure:"signal_icons"`
+	// Whether to count the traffic of each interface over a billing period.
+	// The counts are kept across restarts and reboots in
+	// $XDG_STATE_HOME/gobar/traffic.
+	Accounting bool `mapstructure:"accounting"`
+	// The day of the month billing periods start on. Defaults to 1.
+	BillingDay int `mapstructure:"billing_day"`
+	// The traffic allowed per billing period, for example "20GB" or
+	// "15GiB". An interface is colored yellow once QuotaWarning percent of
+	// it is used and red once it is used up. Zero disables the quota.
+	Quota        byteSize `mapstructure:"quota"`
+	QuotaWarning float64  `mapstructure:"quota_warning"`
 
-	patternRe *regexp.Regexp
-	ifaces    []iface
+	includeRes []*regexp.Regexp
+	excludeRes []*regexp.Regexp
+	format     *template.Template
+	ns         netns.NsHandle
+	nsLabel    string
+	handle     *netlink.Handle
+	wireless   wirelessSource
+	trafficDir string
+	bootID     string
+	ifaces     []iface
+	// indexes of the links carrying the best default routes
+	primary map[int]struct{}
 }
 
 func (n *Network) valid() bool {
-	return (n.Pattern != nil && n.Interface == nil) ||
-		(n.Pattern == nil && n.Interface != nil)
+	return n.Interface != nil || n.Pattern != nil || len(n.Interfaces) > 0 || len(n.Include) > 0
 }
 
-func (n *Network) init() error {
-	if n.Pattern != nil {
+// newIface returns an interface for the link with its addresses hidden.
+func (n *Network) newIface(link netlink.Link, exact bool) iface {
+	i := iface{exact: exact, hideIP: true}
+	n.setLink(&i, link)
+	return i
+}
+
+// setLink updates the interface with new link attributes, reading the link
+// speed and duplex that aren't available over netlink from sysfs. The bar's
+// sysfs only has the links of its own namespace, so these are left empty for
+// other namespaces.
+func (n *Network) setLink(i *iface, link netlink.Link) {
+	i.link = link
+	i.speed, i.duplex = 0, ""
+	if n.Netns != "" {
+		return
+	}
+
+	dir := filepath.Join("/sys/class/net", link.Attrs().Name)
+	// Reading the speed fails for links without a carrier and drivers that
+	// don't report it.
+	if speed, err := readSysfsFloat(filepath.Join(dir, "speed")); err == nil && speed > 0 {
+		i.speed = int(speed)
+	}
+	i.duplex = readSysfsString(filepath.Join(dir, "duplex"))
+}
+
+// hasCarrier returns whether the link has a carrier.
+func hasCarrier(link netlink.Link) bool {
+	return link.Attrs().RawFlags&unix.IFF_LOWER_UP != 0
+}
+
+// linkDown returns whether the link isn't able to pass packets.
+func linkDown(link netlink.Link) bool {
+	switch link.Attrs().OperState {
+	case netlink.OperDown, netlink.OperLowerLayerDown, netlink.OperNotPresent:
+		return true
+	case netlink.OperUnknown:
+		// Virtual links such as tun and wireguard devices don't report an
+		// operational state.
+		return !hasCarrier(link)
+	default:
+		return false
+	}
+}
+
+// compile compiles the configured format and patterns.
+func (n *Network) compile() error {
+	if n.Format != "" {
 		var err error
-		n.patternRe, err = regexp.Compile(*n.Pattern)
+		n.format, err = template.New("network").Parse(n.Format)
 		if err != nil {
 			return err
 		}
+	}
+
+	if n.Interface != nil {
+		n.Interfaces = append(n.Interfaces, *n.Interface)
+	}
+	if n.Pattern != nil {
+		n.Include = append(n.Include, *n.Pattern)
+	}
 
-		links, err := netlink.LinkList()
+	for _, pattern := range n.Include {
+		re, err := regexp.Compile(pattern)
 		if err != nil {
 			return err
 		}
-		matchedNone := true
-		for _, link := range links {
-			if matched := n.patternRe.MatchString(link.Attrs().Name); matched {
-				matchedNone = false
-				n.ifaces = append(n.ifaces, iface{link: link, hideIP: true})
-			}
+		n.includeRes = append(n.includeRes, re)
+	}
+	if err := n.AddressPolicy.compile(); err != nil {
+		return err
+	}
+
+	for _, pattern := range n.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		n.excludeRes = append(n.excludeRes, re)
+	}
+
+	return nil
+}
+
+// matches returns whether the link is one of the configured interfaces, and
+// whether it was configured by its exact name. Interfaces configured by their
+// exact name aren't subject to the exclude patterns and kind filters.
+func (n *Network) matches(link netlink.Link) (matched bool, exact bool) {
+	name := link.Attrs().Name
+	if slices.Contains(n.Interfaces, name) {
+		return true, true
+	}
+
+	kind := link.Type()
+	if len(n.Kinds) > 0 && !slices.Contains(n.Kinds, kind) {
+		return false, false
+	}
+	if slices.Contains(n.ExcludeKinds, kind) {
+		return false, false
+	}
+
+	for _, re := range n.excludeRes {
+		if re.MatchString(name) {
+			return false, false
+		}
+	}
+	for _, re := range n.includeRes {
+		if re.MatchString(name) {
+			return true, false
+		}
+	}
+
+	return false, false
+}
+
+// openNetns opens a netlink handle in the configured network namespace, or in
+// the bar's own namespace if there is none.
+func (n *Network) openNetns() error {
+	n.ns = netns.None()
+	if n.Netns == "" {
+		n.handle = &netlink.Handle{}
+		return nil
+	}
+
+	var err error
+	if strings.Contains(n.Netns, "/") {
+		n.ns, err = netns.GetFromPath(n.Netns)
+	} else {
+		n.ns, err = netns.GetFromName(n.Netns)
+	}
+	if err != nil {
+		return err
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(int(n.ns), &stat); err != nil {
+		n.ns.Close()
+		n.ns = netns.None()
+		return err
+	}
+	n.nsLabel = netnsLabel(n.Netns, stat.Ino)
+
+	n.handle, err = netlink.NewHandleAt(n.ns)
+	if err != nil {
+		n.ns.Close()
+		n.ns = netns.None()
+	}
+	return err
+}
+
+// netnsLabel returns the default name of a namespace shown in blocks. Names
+// and paths of namespaces created by "ip netns add" are unique, unlike the
+// last element of other paths such as "/proc/1234/ns/net", which are named
+// by the namespace's inode instead.
+func netnsLabel(netns string, inode uint64) string {
+	if !strings.Contains(netns, "/") {
+		return netns
+	}
+	if dir := filepath.Dir(netns); dir == "/var/run/netns" || dir == "/run/netns" {
+		return filepath.Base(netns)
+	}
+	return fmt.Sprintf("netns-%d", inode)
+}
+
+// closeNetns closes the netlink handle and namespace opened by openNetns.
+func (n *Network) closeNetns() {
+	if n.Netns == "" {
+		return
+	}
+	if n.handle != nil {
+		n.handle.Delete()
+	}
+	n.ns.Close()
+}
+
+// netnsName returns the name of the configured network namespace as shown in
+// blocks, for example "vpn" for both "vpn" and "/var/run/netns/vpn".
+func (n *Network) netnsName() string {
+	if n.Netns == "" {
+		return ""
+	}
+	if n.NetnsName != "" {
+		return n.NetnsName
+	}
+	return n.nsLabel
+}
+
+// label returns the name of an interface as shown in blocks, prefixed with
+// the name of the network namespace if one is configured.
+func (n *Network) label(name string) string {
+	if ns := n.netnsName(); ns != "" {
+		return ns + ":" + name
+	}
+	return name
+}
+
+func (n *Network) init() error {
+	links, err := n.handle.LinkList()
+	if err != nil {
+		return err
+	}
+
+	ifaces := []iface{}
+	for _, link := range links {
+		matched, exact := n.matches(link)
+		if !matched {
+			continue
 		}
-		if matchedNone {
-			return errNetworkNoMatch
+
+		i := n.newIface(link, exact)
+		// Keep what was toggled with clicks and the last sample of the link
+		// statistics when resyncing an interface that is already shown.
+		if idx := slices.IndexFunc(n.ifaces, func(old iface) bool {
+			return old.link.Attrs().Index == link.Attrs().Index
+		}); idx >= 0 {
+			old := n.ifaces[idx]
+			i.hideIP, i.detailed = old.hideIP, old.detailed
+			i.rxBytes, i.txBytes, i.sampled = old.rxBytes, old.txBytes, old.sampled
+			i.rxRate, i.txRate = old.rxRate, old.txRate
+			i.wireless = old.wireless
+			i.traffic, i.trafficSaved, i.trafficErr = old.traffic, old.trafficSaved, old.trafficErr
 		}
-	} else if n.Interface != nil && n.ifaces == nil {
-		link, err := netlink.LinkByName(*n.Interface)
+
+		addrs, err := n.handle.AddrList(link, netlink.FAMILY_ALL)
 		if err != nil {
 			return err
 		}
-		n.ifaces = append(n.ifaces, iface{link: link, hideIP: true})
+		i.addrs = addrs
+		i.choosePreferred(&n.AddressPolicy)
+
+		ifaces = append(ifaces, i)
 	}
+	n.ifaces = ifaces
 
-	for i, iface := range n.ifaces {
-		v4addrs, err := netlink.AddrList(iface.link, unix.AF_INET)
+	if len(n.ifaces) == 0 {
+		return errNetworkNoMatch
+	}
+
+	return nil
+}
+
+// resync replaces the interfaces, their addresses and the primary interfaces
+// with the kernel's current state. Interfaces matched by a pattern can come
+// and go, so having none is not an error.
+func (n *Network) resync() error {
+	if err := n.init(); err != nil && !errors.Is(err, errNetworkNoMatch) {
+		return err
+	}
+	if n.Primary {
+		return n.updatePrimary()
+	}
+	return nil
+}
+
+// networkSubscription holds the channels of the netlink subscriptions. The
+// zero value has nil channels, which never receive.
+type networkSubscription struct {
+	links  chan netlink.LinkUpdate
+	addrs  chan netlink.AddrUpdate
+	routes chan netlink.RouteUpdate
+	// receives a single error once the subscription fails, however many of
+	// fail's callers report the failure
+	failed chan error
+	fail   func(error)
+	done   chan struct{}
+}
+
+func newNetworkSubscription() networkSubscription {
+	failed := make(chan error, 1)
+	var once sync.Once
+	return networkSubscription{
+		links:  make(chan netlink.LinkUpdate),
+		addrs:  make(chan netlink.AddrUpdate),
+		failed: failed,
+		fail: func(err error) {
+			once.Do(func() {
+				failed <- err
+			})
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// subscribe subscribes to link and address updates, and to route updates if
+// only the primary interfaces are shown.
+func (n *Network) subscribe() (networkSubscription, error) {
+	sub := newNetworkSubscription()
+	// netlink reports an error before closing the update channel of the
+	// failed subscription, and both are reported as the same failure.
+	onError := sub.fail
+
+	if err := netlink.LinkSubscribeWithOptions(sub.links, sub.done, netlink.LinkSubscribeOptions{
+		Namespace:     &n.ns,
+		ErrorCallback: onError,
+	}); err != nil {
+		close(sub.done)
+		return networkSubscription{}, err
+	}
+
+	if err := netlink.AddrSubscribeWithOptions(sub.addrs, sub.done, netlink.AddrSubscribeOptions{
+		Namespace:     &n.ns,
+		ErrorCallback: onError,
+	}); err != nil {
+		sub.close()
+		return networkSubscription{}, err
+	}
+
+	if n.Primary {
+		sub.routes = make(chan netlink.RouteUpdate)
+		if err := netlink.RouteSubscribeWithOptions(sub.routes, sub.done, netlink.RouteSubscribeOptions{
+			Namespace:     &n.ns,
+			ErrorCallback: onError,
+		}); err != nil {
+			sub.routes = nil
+			sub.close()
+			return networkSubscription{}, err
+		}
+	}
+
+	return sub, nil
+}
+
+// close closes the netlink sockets of the subscription. The update channels
+// are drained until netlink closes them so that its goroutines don't block.
+func (s networkSubscription) close() {
+	if s.done == nil {
+		return
+	}
+	close(s.done)
+	if s.links != nil {
+		go func() {
+			for range s.links {
+			}
+		}()
+	}
+	if s.addrs != nil {
+		go func() {
+			for range s.addrs {
+			}
+		}()
+	}
+	if s.routes != nil {
+		go func() {
+			for range s.routes {
+			}
+		}()
+	}
+}
+
+// humanBits formats a number of bits using SI prefixes, for example
+// "1.5Mbit".
+func humanBits(n float64) string {
+	units := []string{"bit", "kbit", "Mbit", "Gbit", "Tbit"}
+	i := 0
+	for n >= 1000 && i < len(units)-1 {
+		n /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", int(n), units[i])
+	}
+	return fmt.Sprintf("%.1f%s", n, units[i])
+}
+
+func (n *Network) formatRate(bytesPerSec float64) string {
+	if n.RateUnit == "bits" {
+		return humanBits(bytesPerSec*8) + "/s"
+	}
+	return humanBytes(bytesPerSec) + "/s"
+}
+
+// updatePrimary finds the links carrying the default IPv4 and IPv6 routes
+// with the lowest metric. Routes of all tables are considered, since VPNs
+// such as wg-quick install their default route in a policy routing table.
+func (n *Network) updatePrimary() error {
+	n.primary = map[int]struct{}{}
+
+	for _, family := range []int{unix.AF_INET, unix.AF_INET6} {
+		routes, err := n.handle.RouteListFiltered(family, &netlink.Route{Table: unix.RT_TABLE_UNSPEC}, netlink.RT_FILTER_TABLE)
 		if err != nil {
 			return err
 		}
-		if len(v4addrs) > 0 {
-			sort.SliceStable(v4addrs, func(i, j int) bool {
-				return prioritizeIPv4(v4addrs[i].IP) >= prioritizeIPv4(v4addrs[j].IP)
-			})
-			if prioritizeIPv4(v4addrs[0].IP) > 0 {
-				n.ifaces[i].ipv4 = v4addrs[0].IP
-				n.ifaces[i].ipv4Mask = v4addrs[0].Mask
+		for _, index := range primaryLinks(routes) {
+			n.primary[index] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+// defaultRouteBits returns the prefix length of a route's destination, zero
+// for a default route.
+func defaultRouteBits(route netlink.Route) int {
+	if route.Dst == nil {
+		return 0
+	}
+	ones, _ := route.Dst.Mask.Size()
+	return ones
+}
+
+// routeLinks returns the indexes of the links a route goes through.
+func routeLinks(route netlink.Route) []int {
+	links := []int{}
+	if route.LinkIndex > 0 {
+		links = append(links, route.LinkIndex)
+	}
+	for _, nexthop := range route.MultiPath {
+		links = append(links, nexthop.LinkIndex)
+	}
+	return links
+}
+
+// primaryLinks returns the indexes of the links carrying the default route
+// with the lowest metric among routes of a single family. A pair of /1
+// routes covering the whole address space, as installed by OpenVPN's def1
+// option, overrides the default route and takes precedence over it.
+func primaryLinks(routes []netlink.Route) []int {
+	var best *netlink.Route
+	halves := map[int]map[bool]bool{}
+	for i, route := range routes {
+		links := routeLinks(route)
+		// Skip blackhole and unreachable routes, which have no link.
+		if len(links) == 0 {
+			continue
+		}
+
+		switch defaultRouteBits(route) {
+		case 0:
+			if best == nil || route.Priority < best.Priority {
+				best = &routes[i]
+			}
+		case 1:
+			// The first bit of the destination tells the two halves apart.
+			upper := route.Dst.IP.To16()[0]&0x80 != 0
+			if ip4 := route.Dst.IP.To4(); ip4 != nil {
+				upper = ip4[0]&0x80 != 0
+			}
+			for _, link := range links {
+				if halves[link] == nil {
+					halves[link] = map[bool]bool{}
+				}
+				halves[link][upper] = true
 			}
 		}
+	}
 
-		v6addrs, err := netlink.AddrList(iface.link, unix.AF_INET6)
+	primary := []int{}
+	for link, seen := range halves {
+		if seen[false] && seen[true] {
+			primary = append(primary, link)
+		}
+	}
+	if len(primary) > 0 {
+		slices.Sort(primary)
+		return primary
+	}
+	if best != nil {
+		return routeLinks(*best)
+	}
+	return primary
+}
+
+// showFamily returns whether addresses of the family, "ipv4" or "ipv6", are
+// shown.
+func (n *Network) showFamily(family string) bool {
+	return len(n.Families) == 0 || slices.Contains(n.Families, family)
+}
+
+func (n *Network) formatAddr(ip net.IP, mask net.IPMask) string {
+	if ip == nil {
+		return ""
+	}
+	if n.CIDR && mask != nil {
+		ones, _ := mask.Size()
+		return fmt.Sprintf("%s/%d", ip, ones)
+	}
+	return ip.String()
+}
+
+// sampling returns whether the link statistics need to be sampled.
+func (n *Network) sampling() bool {
+	return n.Rates || n.Wireless || n.Accounting || n.format != nil
+}
+
+// trafficSaveInterval is how often the traffic counters are persisted.
+const trafficSaveInterval = time.Minute
+
+// account adds the traffic since the last sample to the interface's traffic
+// counter, loading it on the first sample.
+func (n *Network) account(i *iface, rx, tx uint64, now time.Time) error {
+	path := filepath.Join(n.trafficDir, n.label(i.link.Attrs().Name)+".json")
+	if i.traffic == nil {
+		traffic, err := loadTrafficCounter(path)
 		if err != nil {
 			return err
 		}
+		i.traffic = traffic
+	}
 
-		if len(v6addrs) > 0 {
-			sort.SliceStable(v6addrs, func(i, j int) bool {
-				return prioritizeIPv6(v6addrs[i].IP, v6addrs[i].Flags) >= prioritizeIPv6(v6addrs[j].IP, v6addrs[j].Flags)
-			})
-			if prioritizeIPv6(v6addrs[0].IP, v6addrs[0].Flags) > 0 {
-				n.ifaces[i].ipv6 = v6addrs[0].IP
-				n.ifaces[i].ipv6Mask = v6addrs[0].Mask
-			}
+	periodStart := billingPeriodStart(now, n.BillingDay)
+	newPeriod := !i.traffic.PeriodStart.Equal(periodStart)
+	i.traffic.update(rx, tx, i.link.Attrs().Index, n.bootID, periodStart)
+
+	if newPeriod || now.Sub(i.trafficSaved) >= trafficSaveInterval {
+		if err := i.traffic.save(path); err != nil {
+			return err
+		}
+		i.trafficSaved = now
+	}
+	return nil
+}
+
+// accountOrLog accounts the traffic of the interface. Failing to load or save
+// its counter, such as when the file is corrupt or the state directory isn't
+// writable, only drops its traffic from its block, so the error is logged
+// once instead of being shown.
+func (n *Network) accountOrLog(i *iface, rx, tx uint64, now time.Time) {
+	err := n.account(i, rx, tx, now)
+	if err != nil {
+		if i.trafficErr == nil || i.trafficErr.Error() != err.Error() {
+			log.Printf("network: failed to account traffic of %s: %v", i.link.Attrs().Name, err)
+		}
+		// The counter is loaded again on the next sample, which continues
+		// from the last saved sample.
+		i.traffic = nil
+	}
+	i.trafficErr = err
+}
+
+// quotaPercent returns the percentage of the quota used by the interface.
+func (n *Network) quotaPercent(i iface) float64 {
+	if n.Quota <= 0 || i.traffic == nil {
+		return 0
+	}
+	return i.traffic.total() / float64(n.Quota) * 100
+}
+
+// sampleWireless updates the details of the connection of each wireless
+// interface. An interface that fails to report them, for example while
+// roaming or being brought down, keeps its last details so that the error
+// doesn't hide the other interfaces.
+func (n *Network) sampleWireless() {
+	for i, iface := range n.ifaces {
+		info, err := n.wireless.wireless(iface.link)
+		if errors.Is(err, errNotWireless) {
+			n.ifaces[i].wireless = nil
+			continue
+		} else if err != nil {
+			continue
+		}
+		n.ifaces[i].wireless = &info
+	}
+}
+
+// signalIcon returns the icon for the signal quality percentage.
+func (n *Network) signalIcon(quality int) string {
+	icons := n.SignalIcons
+	if len(icons) == 0 {
+		for _, bar := range graphBars {
+			icons = append(icons, string(bar))
+		}
+	}
+	idx := quality * len(icons) / 101
+	if idx < 0 {
+		idx = 0
+	}
+	return icons[idx]
+}
+
+// sampleRates updates the receive and transmit rates of each interface from
+// the link statistics reported by the kernel.
+func (n *Network) sampleRates() error {
+	links, err := n.handle.LinkList()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, iface := range n.ifaces {
+		idx := slices.IndexFunc(links, func(link netlink.Link) bool {
+			return link.Attrs().Index == iface.link.Attrs().Index
+		})
+		if idx < 0 {
+			continue
+		}
+		stats := links[idx].Attrs().Statistics
+		if stats == nil {
+			continue
+		}
+
+		// The counters are reset when a driver is reloaded, in which case
+		// the rates are computed from the next sample.
+		if !iface.sampled.IsZero() && stats.RxBytes >= iface.rxBytes && stats.TxBytes >= iface.txBytes {
+			secs := now.Sub(iface.sampled).Seconds()
+			n.ifaces[i].rxRate = float64(stats.RxBytes-iface.rxBytes) / secs
+			n.ifaces[i].txRate = float64(stats.TxBytes-iface.txBytes) / secs
+		}
+		if n.Accounting {
+			n.accountOrLog(&n.ifaces[i], stats.RxBytes, stats.TxBytes, now)
 		}
+		n.ifaces[i].rxBytes = stats.RxBytes
+		n.ifaces[i].txBytes = stats.TxBytes
+		n.ifaces[i].sampled = now
 	}
 
 	return nil
 }
 
+// text returns the full text of an interface's block.
+func (n *Network) text(iface iface) (string, error) {
+	fields := networkFields{
+		Name:   iface.link.Attrs().Name,
+		Netns:  n.netnsName(),
+		RX:     n.formatRate(iface.rxRate),
+		TX:     n.formatRate(iface.txRate),
+		RXRate: iface.rxRate,
+		TXRate: iface.txRate,
+
+		State:   iface.link.Attrs().OperState.String(),
+		Carrier: hasCarrier(iface.link),
+		Speed:   iface.speed,
+		Duplex:  iface.duplex,
+		MTU:     iface.link.Attrs().MTU,
+		Kind:    iface.link.Type(),
+	}
+
+	if w := iface.wireless; w != nil {
+		fields.Wireless = true
+		fields.Connected = w.connected
+		fields.SSID = w.ssid
+		fields.Signal = w.signal
+		fields.Quality = w.quality
+		fields.SignalIcon = n.signalIcon(w.quality)
+		fields.Band = w.band()
+		fields.Frequency = w.frequency
+		fields.Bitrate = w.bitrate
+	}
+
+	if iface.traffic != nil {
+		fields.TrafficBytes = iface.traffic.total()
+		fields.Traffic = humanBytes(fields.TrafficBytes)
+		fields.QuotaPercent = n.quotaPercent(iface)
+	}
+
+	if !iface.hideIP {
+		if n.showFamily("ipv4") {
+			fields.IPv4 = n.formatAddr(iface.ipv4, iface.ipv4Mask)
+		}
+		if n.showFamily("ipv6") {
+			fields.IPv6 = n.formatAddr(iface.ipv6, iface.ipv6Mask)
+		}
+	}
+
+	if iface.detailed {
+		for _, addr := range iface.addrs {
+			family := "ipv6"
+			if addr.IP.To4() != nil {
+				family = "ipv4"
+			}
+			if n.showFamily(family) {
+				fields.Addresses = append(fields.Addresses, n.formatAddr(addr.IP, addr.Mask))
+			}
+		}
+	}
+
+	if n.format != nil {
+		var buf bytes.Buffer
+		if err := n.format.Execute(&buf, fields); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	parts := []string{n.label(fields.Name)}
+	if n.Wireless && fields.Connected {
+		if fields.SSID != "" {
+			parts = append(parts, fields.SSID)
+		}
+		parts = append(parts, fmt.Sprintf("%s%d%%", fields.SignalIcon, fields.Quality))
+		if iface.detailed {
+			if fields.Band != "" {
+				parts = append(parts, fields.Band)
+			}
+			parts = append(parts, fmt.Sprintf("%ddBm", fields.Signal))
+			if fields.Bitrate > 0 {
+				parts = append(parts, fmt.Sprintf("%gMbit/s", fields.Bitrate))
+			}
+		}
+	}
+	if iface.detailed {
+		parts = append(parts, fields.Addresses...)
+	} else {
+		for _, addr := range []string{fields.IPv4, fields.IPv6} {
+			if addr != "" {
+				parts = append(parts, addr)
+			}
+		}
+	}
+	text := strings.Join(parts, " ")
+	if n.Rates {
+		text += fmt.Sprintf(" ↓%s ↑%s", fields.RX, fields.TX)
+	}
+	if n.Accounting && fields.Traffic != "" {
+		text += " Σ" + fields.Traffic
+		if n.Quota > 0 {
+			text += "/" + humanBytes(float64(n.Quota))
+		}
+	}
+	return text, nil
+}
+
 func (n *Network) print(tx chan []i3.Block, err error, c col.Color) {
 	if err != nil {
 		tx <- []i3.Block{{
-			Name:      "network",
-			Instance:  "network",
-			FullText:  fmt.Sprintf("network: %s", err),
-			ShortText: "network: error",
-			MinWidth:  len("network: error"),
-			Color:     c.Red(),
+			Name:         "network",
+			Instance:     "network",
+			FullText:     fmt.Sprintf("network: %s", err),
+			ShortText:    "network: error",
+			MinWidthText: "network: error",
+			Color:        c.Red(),
 		}}
 		return
 	}
 	if len(n.ifaces) == 0 {
 		tx <- []i3.Block{{
-			Name:      "network",
-			Instance:  "network",
-			FullText:  "network: no interfaces",
-			ShortText: "network: no interfaces",
-			MinWidth:  len("network: no interfaces"),
-			Color:     c.Red(),
+			Name:         "network",
+			Instance:     "network",
+			FullText:     "network: no interfaces",
+			ShortText:    "network: no interfaces",
+			MinWidthText: "network: no interfaces",
+			Color:        c.Red(),
 		}}
 		return
 	}
 
 	blocks := []i3.Block{}
 
-	disconnectedInterfaces := 0
 	for _, iface := range n.ifaces {
 		var printColor string
 
+		if n.Primary {
+			if _, ok := n.primary[iface.link.Attrs().Index]; !ok {
+				continue
+			}
+		}
+
 		name := iface.link.Attrs().Name
+		label := n.label(name)
 
 		switch true {
 		case iface.ipv4 != nil && iface.ipv6 != nil:
@@ -156,108 +977,329 @@ func (n *Network) print(tx chan []i3.Block, err error, c col.Color) {
 		case iface.ipv4 == nil && iface.ipv6 != nil:
 			printColor = c.Normal()
 		default:
-			disconnectedInterfaces++
-			if n.patternRe != nil {
+			if !iface.exact {
 				continue
 			}
 
 			printColor = c.Red()
 		}
 
+		if linkDown(iface.link) {
+			printColor = c.Red()
+		}
+
+		if w := iface.wireless; w != nil && w.connected {
+			if w.signal < n.SignalCritical {
+				printColor = c.Red()
+			} else if w.signal < n.SignalWarning && printColor == c.Normal() {
+				printColor = c.Yellow()
+			}
+		}
+
+		if quota := n.quotaPercent(iface); quota >= 100 {
+			printColor = c.Red()
+		} else if quota >= n.QuotaWarning && n.Quota > 0 && printColor == c.Normal() {
+			printColor = c.Yellow()
+		}
+
+		if rate := math.Max(iface.rxRate, iface.txRate); n.RateCritical > 0 && rate > n.RateCritical {
+			printColor = c.Red()
+		} else if n.RateWarning > 0 && rate > n.RateWarning && printColor == c.Normal() {
+			printColor = c.Yellow()
+		}
+
+		text, err := n.text(iface)
+		if err != nil {
+			text = fmt.Sprintf("%s: %s", name, err)
+			printColor = c.Red()
+		}
+
 		blocks = append(blocks, i3.Block{
-			Name:     "network",
-			Instance: name,
-			FullText: name,
-			MinWidth: len(name),
-			Color:    printColor,
+			Name:         "network",
+			Instance:     name,
+			FullText:     text,
+			ShortText:    label,
+			MinWidthText: label,
+			Color:        printColor,
 		})
 	}
 
-	if n.patternRe != nil && len(n.ifaces) == disconnectedInterfaces {
+	if len(blocks) == 0 {
 		text := "NET: none"
 		blocks = append(blocks, i3.Block{
-			Name:     "network",
-			Instance: "network",
-			FullText: text,
-			MinWidth: len(text),
-			Color:    c.Red(),
+			Name:         "network",
+			Instance:     "network",
+			FullText:     text,
+			MinWidthText: text,
+			Color:        c.Red(),
 		})
 	}
 
 	tx <- blocks
 }
 
+// updateAddrs adds or removes the address in the update from the interface's
+// list of addresses.
+func (i *iface) updateAddrs(update netlink.AddrUpdate) {
+	idx := slices.IndexFunc(i.addrs, func(addr netlink.Addr) bool {
+		return addr.IP.Equal(update.LinkAddress.IP)
+	})
+	if idx >= 0 {
+		i.addrs = slices.Delete(i.addrs, idx, idx+1)
+	}
+	if update.NewAddr {
+		ipNet := update.LinkAddress
+		i.addrs = append(i.addrs, netlink.Addr{
+			IPNet:       &ipNet,
+			Flags:       update.Flags,
+			Scope:       update.Scope,
+			PreferedLft: update.PreferedLft,
+			ValidLft:    update.ValidLft,
+		})
+	}
+}
+
+// choosePreferred sets the preferred IPv4 and IPv6 addresses of the interface
+// to its highest scoring addresses, if they score above zero.
+func (i *iface) choosePreferred(policy *AddressPolicy) {
+	i.ipv4, i.ipv4Mask = nil, nil
+	i.ipv6, i.ipv6Mask = nil, nil
+
+	best4, best6 := 0, 0
+	for _, addr := range i.addrs {
+		if addr.IP.To4() != nil {
+			if score := policy.prioritizeIPv4(addr.IP); score > best4 {
+				best4 = score
+				i.ipv4, i.ipv4Mask = addr.IP, addr.Mask
+			}
+		} else if score := policy.prioritizeIPv6(addr.IP, addr.Flags); score > best6 {
+			best6 = score
+			i.ipv6, i.ipv6Mask = addr.IP, addr.Mask
+		}
+	}
+}
+
+// updateLink adds, updates or removes the interface of a link update and
+// returns whether the interfaces changed. A link that is renamed so that it
+// no longer matches is removed, and one renamed so that it matches is added
+// along with its addresses.
+func (n *Network) updateLink(update netlink.LinkUpdate) bool {
+	matched, exact := n.matches(update.Link)
+	idx := slices.IndexFunc(n.ifaces, func(i iface) bool {
+		return int(update.Index) == i.link.Attrs().Index
+	})
+
+	switch {
+	case idx < 0 && (!matched || update.Header.Type != unix.RTM_NEWLINK):
+		return false
+	case !matched || update.Header.Type == unix.RTM_DELLINK:
+		n.ifaces = slices.Delete(n.ifaces, idx, idx+1)
+	case idx < 0:
+		i := n.newIface(update.Link, exact)
+		if addrs, err := n.handle.AddrList(update.Link, netlink.FAMILY_ALL); err == nil {
+			i.addrs = addrs
+			i.choosePreferred(&n.AddressPolicy)
+		}
+		n.ifaces = append(n.ifaces, i)
+	default:
+		n.setLink(&n.ifaces[idx], update.Link)
+		n.ifaces[idx].exact = exact
+	}
+	return true
+}
+
+// toggle changes the state of the interface shown in instance.
+func (n *Network) toggle(instance string, f func(*iface)) {
+	for i, iface := range n.ifaces {
+		if iface.link.Attrs().Name == instance {
+			f(&n.ifaces[i])
+		}
+	}
+}
+
 // Run implements Module.
 func (n *Network) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 	if !n.valid() {
-		n.print(tx, errNetworkInvalidPattern, c)
+		n.print(tx, errNetworkNoSelection, c)
 		return
 	}
 
-	if err := n.init(); err != nil {
+	if err := n.compile(); err != nil {
 		n.print(tx, err, c)
 		return
 	}
 
-	// Print initial info for all configured network interfaces.
-	n.print(tx, nil, c)
+	if err := n.openNetns(); err != nil {
+		n.print(tx, err, c)
+		return
+	}
+	defer n.closeNetns()
 
-	linkUpdates := make(chan netlink.LinkUpdate)
-	addrUpdates := make(chan netlink.AddrUpdate)
-	done := make(chan struct{}, 1)
-	defer func() {
-		close(linkUpdates)
-		close(addrUpdates)
-		close(done)
-	}()
+	if n.Wireless && n.wireless == nil {
+		var err error
+		if n.wireless, err = newWirelessSource(n.ns); err != nil {
+			n.print(tx, err, c)
+			return
+		}
+		defer n.wireless.close()
+	}
 
-	if err := netlink.LinkSubscribe(linkUpdates, done); err != nil {
+	// Interfaces matched by a pattern can show up later, such as a VPN's
+	// tunnel or a docked ethernet adapter, so having none is not an error.
+	if err := n.init(); err != nil && !errors.Is(err, errNetworkNoMatch) {
 		n.print(tx, err, c)
 		return
 	}
 
-	if err := netlink.AddrSubscribe(addrUpdates, done); err != nil {
+	if n.Interval <= 0 {
+		n.Interval = 5 * time.Second
+	}
+	if n.ResyncInterval <= 0 {
+		n.ResyncInterval = 5 * time.Minute
+	}
+	if n.SignalWarning == 0 {
+		n.SignalWarning = -70
+	}
+	if n.SignalCritical == 0 {
+		n.SignalCritical = -80
+	}
+	if n.BillingDay <= 0 {
+		n.BillingDay = 1
+	}
+	if n.QuotaWarning == 0 {
+		n.QuotaWarning = 80
+	}
+	if n.Accounting {
+		var err error
+		if n.trafficDir, err = trafficStateDir(); err != nil {
+			n.print(tx, err, c)
+			return
+		}
+		n.bootID = readBootID()
+	}
+
+	if n.Primary {
+		if err := n.updatePrimary(); err != nil {
+			n.print(tx, err, c)
+			return
+		}
+	}
+
+	// Print initial info for all configured network interfaces.
+	n.print(tx, nil, c)
+
+	sub, err := n.subscribe()
+	if err != nil {
 		n.print(tx, err, c)
 		return
 	}
+	defer func() {
+		sub.close()
+	}()
+
+	// resubscribe replaces a failed subscription, such as one whose socket
+	// overflowed, and resyncs since updates may have been missed.
+	resubscribe := func() error {
+		sub.close()
+		var err error
+		if sub, err = n.subscribe(); err != nil {
+			return err
+		}
+		return n.resync()
+	}
+
+	clicks := &i3.Dispatcher{}
+	clicks.HandleButtons(func(click i3.ClickEvent) {
+		n.toggle(click.Instance, func(iface *iface) {
+			iface.hideIP = !iface.hideIP
+		})
+	}, i3.MiddleClick)
+	clicks.HandleButtons(func(click i3.ClickEvent) {
+		n.toggle(click.Instance, func(iface *iface) {
+			iface.detailed = !iface.detailed
+		})
+	}, i3.RightClick)
+
+	ready := make(chan struct{}, 1)
+	resyncs := make(chan struct{}, 1)
+	defer func() {
+		close(ready)
+		close(resyncs)
+	}()
+	if n.sampling() {
+		go func() {
+			ready <- struct{}{}
+		}()
+	}
+	go func() {
+		time.Sleep(n.ResyncInterval)
+		resyncs <- struct{}{}
+	}()
 
 	for {
 		select {
+		case <-ready:
+			go func() {
+				time.Sleep(n.Interval)
+				ready <- struct{}{}
+			}()
+
+			if err := n.sampleRates(); err != nil {
+				n.print(tx, err, c)
+				continue
+			}
+			if n.Wireless {
+				n.sampleWireless()
+			}
+			n.print(tx, nil, c)
+		case <-resyncs:
+			go func() {
+				time.Sleep(n.ResyncInterval)
+				resyncs <- struct{}{}
+			}()
+
+			// A subscription that couldn't be replaced is retried here.
+			if sub.done == nil {
+				err = resubscribe()
+			} else {
+				err = n.resync()
+			}
+			n.print(tx, err, c)
+		case <-sub.failed:
+			n.print(tx, resubscribe(), c)
 		case click := <-rx:
-			switch click.Button {
-			case i3.MiddleClick:
-				for i, iface := range n.ifaces {
-					if iface.link.Attrs().Name == click.Instance {
-						n.ifaces[i].hideIP = !n.ifaces[i].hideIP
-						n.print(tx, nil, c)
-					}
-				}
+			if clicks.Dispatch(click) {
+				n.print(tx, nil, c)
 			}
-		case linkUpdate := <-linkUpdates:
-			if n.patternRe == nil || !n.patternRe.MatchString(linkUpdate.Link.Attrs().Name) {
+		case linkUpdate, ok := <-sub.links:
+			if !ok {
+				sub.links = nil
+				sub.fail(errNetworkSubClosed)
 				continue
 			}
-
-			idx := slices.IndexFunc(n.ifaces, func(i iface) bool {
-				return int(linkUpdate.Index) == i.link.Attrs().Index
-			})
-			switch linkUpdate.Header.Type {
-			case unix.RTM_NEWLINK:
-				if idx < 0 {
-					n.ifaces = append(n.ifaces, iface{link: linkUpdate.Link, hideIP: true})
-				}
-			case unix.RTM_DELLINK:
-				if idx < 0 {
-					continue
-				}
-
-				if idx == len(n.ifaces)-1 {
-					n.ifaces = n.ifaces[0:idx]
-				} else {
-					n.ifaces = append(n.ifaces[0:idx], n.ifaces[idx+1:]...)
-				}
+			if n.updateLink(linkUpdate) {
+				n.print(tx, nil, c)
+			}
+		case routeUpdate, ok := <-sub.routes:
+			if !ok {
+				sub.routes = nil
+				sub.fail(errNetworkSubClosed)
+				continue
+			}
+			if defaultRouteBits(routeUpdate.Route) > 1 {
+				continue
+			}
+			if err := n.updatePrimary(); err != nil {
+				n.print(tx, err, c)
+				continue
+			}
+			n.print(tx, nil, c)
+		case addrUpdate, ok := <-sub.addrs:
+			if !ok {
+				sub.addrs = nil
+				sub.fail(errNetworkSubClosed)
+				continue
 			}
-		case addrUpdate := <-addrUpdates:
 			idx := slices.IndexFunc(n.ifaces, func(i iface) bool {
 				return i.link.Attrs().Index == addrUpdate.LinkIndex
 			})
@@ -265,35 +1307,133 @@ func (n *Network) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
 				continue
 			}
 
-			iface := n.ifaces[idx]
-
-			if addrUpdate.NewAddr {
-				if len(addrUpdate.LinkAddress.IP) == net.IPv4len && prioritizeIPv4(addrUpdate.LinkAddress.IP) >= prioritizeIPv4(n.ifaces[idx].ipv4) {
-					n.ifaces[idx].ipv4 = addrUpdate.LinkAddress.IP
-					n.ifaces[idx].ipv4Mask = addrUpdate.LinkAddress.Mask
-				} else if prioritizeIPv6(addrUpdate.LinkAddress.IP, addrUpdate.Flags) >= prioritizeIPv6(n.ifaces[idx].ipv6, 0) {
-					n.ifaces[idx].ipv6 = addrUpdate.LinkAddress.IP
-					n.ifaces[idx].ipv6Mask = addrUpdate.LinkAddress.Mask
-				} else {
-					continue
-				}
-			} else {
-				if iface.ipv4.Equal(addrUpdate.LinkAddress.IP) {
-					n.ifaces[idx].ipv4 = nil
-					n.ifaces[idx].ipv4Mask = nil
-				} else if iface.ipv6.Equal(addrUpdate.LinkAddress.IP) {
-					n.ifaces[idx].ipv6 = nil
-					n.ifaces[idx].ipv6Mask = nil
-				} else {
-					continue
-				}
-			}
+			n.ifaces[idx].updateAddrs(addrUpdate)
+			n.ifaces[idx].choosePreferred(&n.AddressPolicy)
 			n.print(tx, nil, c)
 		}
 	}
 }
 
-func prioritizeIPv4(ip net.IP) int {
+// excludedScore is the score of addresses in an excluded prefix, lower than
+// that of any other address.
+const excludedScore = math.MinInt32
+
+// AddressPolicy configures how the preferred IPv4 and IPv6 addresses of an
+// interface are chosen. The zero value uses the built-in scoring.
+type AddressPolicy struct {
+	// Prefixes whose addresses are preferred over all others, in order of
+	// preference.
+	PreferredPrefixes []string `mapstructure:"preferred_prefixes"`
+	// Prefixes whose addresses are never chosen.
+	ExcludedPrefixes []string `mapstructure:"excluded_prefixes"`
+	// Whether to prefer "temporary" (the default) or "stable" IPv6
+	// addresses. Stable addresses include stable privacy addresses, which
+	// the kernel marks as managing temporary addresses.
+	Prefer string `mapstructure:"prefer"`
+	// An RFC 6724-style policy table. The precedence of the entry with the
+	// longest prefix matching an address is added to its score. IPv4
+	// addresses match IPv4-mapped IPv6 prefixes, such as "::ffff:0:0/96".
+	PolicyTable []AddressPolicyEntry `mapstructure:"policy_table"`
+
+	preferred []netip.Prefix
+	excluded  []netip.Prefix
+	table     []addressPolicyPrefix
+}
+
+// AddressPolicyEntry is an entry in an AddressPolicy's policy table.
+type AddressPolicyEntry struct {
+	Prefix     string `mapstructure:"prefix"`
+	Precedence int    `mapstructure:"precedence"`
+}
+
+type addressPolicyPrefix struct {
+	prefix     netip.Prefix
+	precedence int
+}
+
+func (p *AddressPolicy) compile() error {
+	parse := func(prefixes []string) ([]netip.Prefix, error) {
+		parsed := []netip.Prefix{}
+		for _, prefix := range prefixes {
+			pfx, err := netip.ParsePrefix(prefix)
+			if err != nil {
+				return nil, err
+			}
+			parsed = append(parsed, pfx.Masked())
+		}
+		return parsed, nil
+	}
+
+	var err error
+	if p.preferred, err = parse(p.PreferredPrefixes); err != nil {
+		return err
+	}
+	if p.excluded, err = parse(p.ExcludedPrefixes); err != nil {
+		return err
+	}
+
+	p.table = []addressPolicyPrefix{}
+	for _, entry := range p.PolicyTable {
+		pfx, err := netip.ParsePrefix(entry.Prefix)
+		if err != nil {
+			return err
+		}
+		p.table = append(p.table, addressPolicyPrefix{prefix: pfx.Masked(), precedence: entry.Precedence})
+	}
+
+	switch p.Prefer {
+	case "", "temporary", "stable":
+	default:
+		return fmt.Errorf("invalid address preference %q", p.Prefer)
+	}
+
+	return nil
+}
+
+// contains returns whether the prefix contains the address, matching IPv4
+// addresses against IPv4-mapped IPv6 prefixes.
+func prefixContains(prefix netip.Prefix, addr netip.Addr) bool {
+	if addr.Is4() && prefix.Addr().Is6() {
+		addr = netip.AddrFrom16(addr.As16())
+	}
+	return prefix.Contains(addr)
+}
+
+// adjust applies the configured prefixes and policy table to the score of an
+// address.
+func (p *AddressPolicy) adjust(ip net.IP, score int) int {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return score
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range p.excluded {
+		if prefixContains(prefix, addr) {
+			return excludedScore
+		}
+	}
+
+	for i, prefix := range p.preferred {
+		if prefixContains(prefix, addr) {
+			score += 10000 * (len(p.preferred) - i)
+			break
+		}
+	}
+
+	bestBits := -1
+	precedence := 0
+	for _, entry := range p.table {
+		if prefixContains(entry.prefix, addr) && entry.prefix.Bits() > bestBits {
+			bestBits = entry.prefix.Bits()
+			precedence = entry.precedence
+		}
+	}
+
+	return score + precedence
+}
+
+func (p *AddressPolicy) prioritizeIPv4(ip net.IP) int {
 	if ip == nil {
 		return -1
 	}
@@ -310,22 +1450,27 @@ func prioritizeIPv4(ip net.IP) int {
 		score -= 1000
 	}
 
-	return score
+	return p.adjust(ip, score)
 }
 
-func prioritizeIPv6(ip net.IP, flags int) int {
+func (p *AddressPolicy) prioritizeIPv6(ip net.IP, flags int) int {
 	if ip == nil {
 		return -1
 	}
 
 	score := 0
+	preferStable := p.Prefer == "stable"
 
 	if flags&unix.IFA_F_DEPRECATED > 0 {
 		score -= 1000
 	}
 
 	if flags&unix.IFA_F_TEMPORARY > 0 {
-		score += 300
+		if preferStable {
+			score -= 300
+		} else {
+			score += 300
+		}
 	}
 
 	if flags&unix.IFA_F_PERMANENT > 0 {
@@ -334,7 +1479,11 @@ func prioritizeIPv6(ip net.IP, flags int) int {
 
 	// often not used for routing, often set as primary
 	if flags&unix.IFA_F_MANAGETEMPADDR > 0 {
-		score -= 2000
+		if preferStable {
+			score += 300
+		} else {
+			score -= 2000
+		}
 	}
 
 	// may have been obtained via DHCPv6 or is a temp addr
@@ -364,5 +1513,5 @@ func prioritizeIPv6(ip net.IP, flags int) int {
 		score -= 1000
 	}
 
-	return score
+	return p.adjust(ip, score)
 }
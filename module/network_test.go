@@ -1,18 +1,26 @@
 package module
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"testing"
+	"time"
 
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/exp/slices"
 	"golang.org/x/sys/unix"
 )
 
 func TestPrioritizeIpv6(t *testing.T) {
 	tt := []struct {
-		name  string
-		ip    net.IP
-		flags int
-		want  int
+		name   string
+		policy AddressPolicy
+		ip     net.IP
+		flags  int
+		want   int
 	}{
 		{
 			name:  "localhost",
@@ -50,12 +58,735 @@ func TestPrioritizeIpv6(t *testing.T) {
 			flags: unix.IFA_F_SECONDARY | unix.IFA_F_TEMPORARY,
 			want:  910,
 		},
+		{
+			name:   "gua management address preferring stable",
+			policy: AddressPolicy{Prefer: "stable"},
+			ip:     net.IP{0x20, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01},
+			flags:  unix.IFA_F_NOPREFIXROUTE | unix.IFA_F_MANAGETEMPADDR,
+			want:   1400,
+		},
+		{
+			name:   "gua temporary address preferring stable",
+			policy: AddressPolicy{Prefer: "stable"},
+			ip:     net.IP{0x20, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01},
+			flags:  unix.IFA_F_SECONDARY | unix.IFA_F_TEMPORARY,
+			want:   310,
+		},
+		{
+			name:   "ula management address in preferred prefix",
+			policy: AddressPolicy{PreferredPrefixes: []string{"fd00::/8", "fc00::/8"}},
+			ip:     net.IP{0xfc, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01},
+			flags:  unix.IFA_F_NOPREFIXROUTE | unix.IFA_F_MANAGETEMPADDR,
+			want:   8490,
+		},
+		{
+			name:   "gua temporary address in excluded prefix",
+			policy: AddressPolicy{ExcludedPrefixes: []string{"2000::/16"}},
+			ip:     net.IP{0x20, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01},
+			flags:  unix.IFA_F_SECONDARY | unix.IFA_F_TEMPORARY,
+			want:   excludedScore,
+		},
+		{
+			name: "ula temporary address with policy table",
+			policy: AddressPolicy{PolicyTable: []AddressPolicyEntry{
+				{Prefix: "::/0", Precedence: 40},
+				{Prefix: "fc00::/7", Precedence: 3},
+			}},
+			ip:    net.IP{0xfc, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01},
+			flags: unix.IFA_F_SECONDARY | unix.IFA_F_TEMPORARY,
+			want:  303,
+		},
 	}
 
 	for _, tc := range tt {
-		got := prioritizeIPv6(tc.ip, tc.flags)
+		if err := tc.policy.compile(); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		got := tc.policy.prioritizeIPv6(tc.ip, tc.flags)
 		if got != tc.want {
 			t.Fatalf("%s: got %d, wanted %d\n", tc.name, got, tc.want)
 		}
 	}
 }
+
+func TestPrioritizeIpv4(t *testing.T) {
+	tt := []struct {
+		name   string
+		policy AddressPolicy
+		ip     net.IP
+		want   int
+	}{
+		{
+			name: "private address",
+			ip:   net.IPv4(192, 168, 1, 2).To4(),
+			want: 190,
+		},
+		{
+			name: "link local address",
+			ip:   net.IPv4(169, 254, 1, 2).To4(),
+			want: -1000,
+		},
+		{
+			name:   "excluded prefix",
+			policy: AddressPolicy{ExcludedPrefixes: []string{"192.168.0.0/16"}},
+			ip:     net.IPv4(192, 168, 1, 2).To4(),
+			want:   excludedScore,
+		},
+		{
+			name: "ipv4-mapped policy table entry",
+			policy: AddressPolicy{PolicyTable: []AddressPolicyEntry{
+				{Prefix: "::ffff:0:0/96", Precedence: 35},
+			}},
+			ip:   net.IPv4(10, 0, 0, 1).To4(),
+			want: 225,
+		},
+	}
+
+	for _, tc := range tt {
+		if err := tc.policy.compile(); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		got := tc.policy.prioritizeIPv4(tc.ip)
+		if got != tc.want {
+			t.Fatalf("%s: got %d, wanted %d\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNetworkUpdateLink(t *testing.T) {
+	handle, err := netlink.NewHandle()
+	if err != nil {
+		t.Skipf("netlink unavailable: %v", err)
+	}
+	defer handle.Delete()
+
+	linkUpdate := func(msgType uint16, index int, name string) netlink.LinkUpdate {
+		update := netlink.LinkUpdate{
+			Header: unix.NlMsghdr{Type: msgType},
+			Link:   &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: index, Name: name}},
+		}
+		update.Index = int32(index)
+		return update
+	}
+
+	tt := []struct {
+		name        string
+		update      netlink.LinkUpdate
+		wantChanged bool
+		wantNames   []string
+	}{
+		{
+			name:        "new matching link",
+			update:      linkUpdate(unix.RTM_NEWLINK, 4242, "wg1"),
+			wantChanged: true,
+			wantNames:   []string{"wg0", "wg1"},
+		},
+		{
+			name:      "new link not matching",
+			update:    linkUpdate(unix.RTM_NEWLINK, 4242, "eth0"),
+			wantNames: []string{"wg0"},
+		},
+		{
+			name:        "renamed to match",
+			update:      linkUpdate(unix.RTM_NEWLINK, 4200, "wg-home"),
+			wantChanged: true,
+			wantNames:   []string{"wg-home"},
+		},
+		{
+			name:        "renamed to stop matching",
+			update:      linkUpdate(unix.RTM_NEWLINK, 4200, "eth0"),
+			wantChanged: true,
+			wantNames:   []string{},
+		},
+		{
+			name:        "renamed into exclude",
+			update:      linkUpdate(unix.RTM_NEWLINK, 4200, "wg-test"),
+			wantChanged: true,
+			wantNames:   []string{},
+		},
+		{
+			name:        "deleted",
+			update:      linkUpdate(unix.RTM_DELLINK, 4200, "wg0"),
+			wantChanged: true,
+			wantNames:   []string{},
+		},
+	}
+
+	for _, tc := range tt {
+		n := &Network{Include: []string{"wg.*"}, Exclude: []string{"wg-test"}, Netns: "test", handle: handle}
+		if err := n.compile(); err != nil {
+			t.Fatal(err)
+		}
+		n.ifaces = []iface{n.newIface(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 4200, Name: "wg0"}}, false)}
+
+		if changed := n.updateLink(tc.update); changed != tc.wantChanged {
+			t.Fatalf("%s: got changed %t, wanted %t\n", tc.name, changed, tc.wantChanged)
+		}
+		names := []string{}
+		for _, i := range n.ifaces {
+			names = append(names, i.link.Attrs().Name)
+		}
+		if !slices.Equal(names, tc.wantNames) {
+			t.Fatalf("%s: got %v, wanted %v\n", tc.name, names, tc.wantNames)
+		}
+	}
+}
+
+func TestPrimaryLinks(t *testing.T) {
+	route := func(dst string, link, priority int) netlink.Route {
+		r := netlink.Route{LinkIndex: link, Priority: priority}
+		if dst != "" {
+			_, r.Dst, _ = net.ParseCIDR(dst)
+		}
+		return r
+	}
+
+	tt := []struct {
+		name   string
+		routes []netlink.Route
+		want   []int
+	}{
+		{
+			name:   "no default route",
+			routes: []netlink.Route{route("192.168.1.0/24", 2, 100)},
+			want:   []int{},
+		},
+		{
+			name:   "lowest metric",
+			routes: []netlink.Route{route("", 2, 600), route("", 3, 100), route("192.168.1.0/24", 2, 0)},
+			want:   []int{3},
+		},
+		{
+			name: "policy routing table",
+			routes: []netlink.Route{
+				route("", 2, 100),
+				{LinkIndex: 5, Table: 51820, Dst: nil},
+			},
+			want: []int{5},
+		},
+		{
+			name:   "openvpn def1",
+			routes: []netlink.Route{route("", 2, 100), route("0.0.0.0/1", 6, 0), route("128.0.0.0/1", 6, 0)},
+			want:   []int{6},
+		},
+		{
+			name:   "ipv6 def1",
+			routes: []netlink.Route{route("::/0", 2, 1024), route("::/1", 6, 0), route("8000::/1", 6, 0)},
+			want:   []int{6},
+		},
+		{
+			name:   "single half",
+			routes: []netlink.Route{route("", 2, 100), route("0.0.0.0/1", 6, 0)},
+			want:   []int{2},
+		},
+		{
+			name:   "unreachable default",
+			routes: []netlink.Route{{Type: unix.RTN_UNREACHABLE}, route("", 2, 100)},
+			want:   []int{2},
+		},
+	}
+
+	for _, tc := range tt {
+		if got := primaryLinks(tc.routes); !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: got %v, wanted %v\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNetnsLabel(t *testing.T) {
+	tt := []struct {
+		name      string
+		netns     string
+		netnsName string
+		nsLabel   string
+		want      string
+	}{
+		{name: "own namespace", want: "eth0"},
+		{name: "named", netns: "vpn", nsLabel: netnsLabel("vpn", 4026532281), want: "vpn:eth0"},
+		{name: "var run path", netns: "/var/run/netns/vpn", nsLabel: netnsLabel("/var/run/netns/vpn", 4026532281), want: "vpn:eth0"},
+		{name: "run path", netns: "/run/netns/vpn", nsLabel: netnsLabel("/run/netns/vpn", 4026532281), want: "vpn:eth0"},
+		{name: "proc path", netns: "/proc/1234/ns/net", nsLabel: netnsLabel("/proc/1234/ns/net", 4026532281), want: "netns-4026532281:eth0"},
+		{name: "other proc path", netns: "/proc/5678/ns/net", nsLabel: netnsLabel("/proc/5678/ns/net", 4026532282), want: "netns-4026532282:eth0"},
+		{name: "configured name", netns: "/proc/1234/ns/net", netnsName: "web", nsLabel: netnsLabel("/proc/1234/ns/net", 4026532281), want: "web:eth0"},
+	}
+
+	for _, tc := range tt {
+		n := &Network{Netns: tc.netns, NetnsName: tc.netnsName, nsLabel: tc.nsLabel}
+		if got := n.label("eth0"); got != tc.want {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNetworkOpenNetns(t *testing.T) {
+	tt := []struct {
+		name    string
+		netns   string
+		wantErr bool
+	}{
+		{name: "own namespace"},
+		{name: "path", netns: "/proc/self/ns/net"},
+		{name: "missing name", netns: "gobar-test-missing", wantErr: true},
+		{name: "missing path", netns: "/proc/self/ns/missing", wantErr: true},
+	}
+
+	for _, tc := range tt {
+		n := &Network{Netns: tc.netns}
+		err := n.openNetns()
+		if (err != nil) != tc.wantErr {
+			if errors.Is(err, unix.EPERM) {
+				t.Skipf("%s: entering namespaces is not permitted: %v", tc.name, err)
+			}
+			t.Fatalf("%s: got error %v, wanted error %t\n", tc.name, err, tc.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		want := ""
+		if tc.netns != "" {
+			var stat unix.Stat_t
+			if err := unix.Stat(tc.netns, &stat); err != nil {
+				t.Fatal(err)
+			}
+			want = fmt.Sprintf("netns-%d", stat.Ino)
+		}
+		if got := n.netnsName(); got != want {
+			t.Fatalf("%s: got name %q, wanted %q\n", tc.name, got, want)
+		}
+
+		links, err := n.handle.LinkList()
+		if err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if !slices.ContainsFunc(links, func(link netlink.Link) bool { return link.Attrs().Name == "lo" }) {
+			t.Fatalf("%s: got links %v, wanted lo\n", tc.name, links)
+		}
+		n.closeNetns()
+	}
+}
+
+// upLink returns a link that is up, as used in tests of the blocks.
+func upLink(name string, index int) netlink.Link {
+	return &netlink.Device{LinkAttrs: netlink.LinkAttrs{
+		Name:      name,
+		Index:     index,
+		OperState: netlink.OperUp,
+		RawFlags:  unix.IFF_UP | unix.IFF_LOWER_UP,
+		MTU:       1500,
+	}}
+}
+
+// printOne prints the network module and returns its only block.
+func printOne(t *testing.T, name string, n *Network) i3.Block {
+	t.Helper()
+	tx := make(chan []i3.Block, 1)
+	n.print(tx, nil, col.Color{})
+	blocks := <-tx
+	if len(blocks) != 1 {
+		t.Fatalf("%s: got %d blocks, wanted 1\n", name, len(blocks))
+	}
+	return blocks[0]
+}
+
+func TestHumanBits(t *testing.T) {
+	tt := []struct {
+		bits float64
+		want string
+	}{
+		{bits: 0, want: "0bit"},
+		{bits: 999, want: "999bit"},
+		{bits: 1000, want: "1.0kbit"},
+		{bits: 1.5e6, want: "1.5Mbit"},
+		{bits: 2.5e9, want: "2.5Gbit"},
+		{bits: 3e15, want: "3000.0Tbit"},
+	}
+
+	for _, tc := range tt {
+		if got := humanBits(tc.bits); got != tc.want {
+			t.Fatalf("%v: got %q, wanted %q\n", tc.bits, got, tc.want)
+		}
+	}
+}
+
+func TestNetworkRates(t *testing.T) {
+	c := col.Color{}
+	tt := []struct {
+		name      string
+		n         Network
+		rx, tx    float64
+		wantText  string
+		wantColor string
+	}{
+		{
+			name:      "bytes",
+			n:         Network{Rates: true},
+			rx:        1536,
+			wantText:  "eth0 ↓1.5KiB/s ↑0B/s",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "bits",
+			n:         Network{Rates: true, RateUnit: "bits"},
+			rx:        125000,
+			tx:        100,
+			wantText:  "eth0 ↓1.0Mbit/s ↑800bit/s",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "warning",
+			n:         Network{Rates: true, RateWarning: 1000, RateCritical: 1e6},
+			rx:        2000,
+			wantText:  "eth0 ↓2.0KiB/s ↑0B/s",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "critical on transmit",
+			n:         Network{Rates: true, RateWarning: 1000, RateCritical: 1e6},
+			tx:        2e6,
+			wantText:  "eth0 ↓0B/s ↑1.9MiB/s",
+			wantColor: c.Red(),
+		},
+		{
+			name:      "thresholds without rates shown",
+			n:         Network{RateWarning: 1000},
+			rx:        2000,
+			wantText:  "eth0",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "format",
+			n:         Network{Format: "{{.Name}} {{.RX}} {{.RXRate}}"},
+			rx:        512,
+			wantText:  "eth0 512B/s 512",
+			wantColor: c.Normal(),
+		},
+	}
+
+	for _, tc := range tt {
+		n := tc.n
+		n.Interfaces = []string{"eth0"}
+		if err := n.compile(); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		n.ifaces = []iface{{
+			exact:  true,
+			hideIP: true,
+			link:   upLink("eth0", 2),
+			ipv6:   net.ParseIP("2001:db8::1"),
+			rxRate: tc.rx,
+			txRate: tc.tx,
+		}}
+
+		block := printOne(t, tc.name, &n)
+		if block.FullText != tc.wantText {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, block.FullText, tc.wantText)
+		}
+		if block.Color != tc.wantColor {
+			t.Fatalf("%s: got color %q, wanted %q\n", tc.name, block.Color, tc.wantColor)
+		}
+	}
+}
+
+func TestNetworkAddresses(t *testing.T) {
+	c := col.Color{}
+	ipv4, ipv4Net, _ := net.ParseCIDR("192.0.2.10/24")
+	ipv6, ipv6Net, _ := net.ParseCIDR("2001:db8::10/64")
+	linkLocal, linkLocalNet, _ := net.ParseCIDR("fe80::1/64")
+	addrs := []netlink.Addr{
+		{IPNet: &net.IPNet{IP: ipv4, Mask: ipv4Net.Mask}},
+		{IPNet: &net.IPNet{IP: ipv6, Mask: ipv6Net.Mask}},
+		{IPNet: &net.IPNet{IP: linkLocal, Mask: linkLocalNet.Mask}},
+	}
+
+	tt := []struct {
+		name      string
+		n         Network
+		iface     iface
+		wantText  string
+		wantColor string
+	}{
+		{
+			name:      "both families",
+			iface:     iface{ipv4: ipv4, ipv4Mask: ipv4Net.Mask, ipv6: ipv6, ipv6Mask: ipv6Net.Mask},
+			wantText:  "eth0 192.0.2.10 2001:db8::10",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "hidden",
+			iface:     iface{hideIP: true, ipv4: ipv4, ipv6: ipv6},
+			wantText:  "eth0",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "cidr",
+			n:         Network{CIDR: true},
+			iface:     iface{ipv4: ipv4, ipv4Mask: ipv4Net.Mask, ipv6: ipv6, ipv6Mask: ipv6Net.Mask},
+			wantText:  "eth0 192.0.2.10/24 2001:db8::10/64",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "cidr without mask",
+			n:         Network{CIDR: true},
+			iface:     iface{ipv4: ipv4},
+			wantText:  "eth0 192.0.2.10",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "ipv4 family only",
+			n:         Network{Families: []string{"ipv4"}},
+			iface:     iface{ipv4: ipv4, ipv6: ipv6},
+			wantText:  "eth0 192.0.2.10",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "ipv6 family only",
+			n:         Network{Families: []string{"ipv6"}},
+			iface:     iface{ipv4: ipv4, ipv6: ipv6},
+			wantText:  "eth0 2001:db8::10",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "ipv4 only is colored",
+			iface:     iface{ipv4: ipv4},
+			wantText:  "eth0 192.0.2.10",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "detailed",
+			n:         Network{CIDR: true},
+			iface:     iface{detailed: true, ipv4: ipv4, ipv6: ipv6, addrs: addrs},
+			wantText:  "eth0 192.0.2.10/24 2001:db8::10/64 fe80::1/64",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "detailed ipv6 family only",
+			n:         Network{Families: []string{"ipv6"}},
+			iface:     iface{detailed: true, ipv4: ipv4, ipv6: ipv6, addrs: addrs},
+			wantText:  "eth0 2001:db8::10 fe80::1",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "format",
+			n:         Network{CIDR: true, Format: "{{.IPv4}}|{{.IPv6}}|{{range .Addresses}}[{{.}}]{{end}}"},
+			iface:     iface{detailed: true, ipv4: ipv4, ipv4Mask: ipv4Net.Mask, addrs: addrs[:1]},
+			wantText:  "192.0.2.10/24||[192.0.2.10/24]",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "no addresses on an exact interface",
+			iface:     iface{exact: true},
+			wantText:  "eth0",
+			wantColor: c.Red(),
+		},
+		{
+			name:      "no addresses on a matched interface",
+			iface:     iface{},
+			wantText:  "NET: none",
+			wantColor: c.Red(),
+		},
+	}
+
+	for _, tc := range tt {
+		n := tc.n
+		n.Interfaces = []string{"eth0"}
+		if err := n.compile(); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		i := tc.iface
+		i.link = upLink("eth0", 2)
+		n.ifaces = []iface{i}
+
+		block := printOne(t, tc.name, &n)
+		if block.FullText != tc.wantText {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, block.FullText, tc.wantText)
+		}
+		if block.Color != tc.wantColor {
+			t.Fatalf("%s: got color %q, wanted %q\n", tc.name, block.Color, tc.wantColor)
+		}
+	}
+}
+
+func TestLinkDown(t *testing.T) {
+	tt := []struct {
+		name  string
+		state netlink.LinkOperState
+		flags uint32
+		want  bool
+	}{
+		{name: "up", state: netlink.OperUp, flags: unix.IFF_UP | unix.IFF_LOWER_UP, want: false},
+		{name: "up without carrier flag", state: netlink.OperUp, flags: unix.IFF_UP, want: false},
+		{name: "down", state: netlink.OperDown, flags: unix.IFF_UP, want: true},
+		{name: "lower layer down", state: netlink.OperLowerLayerDown, flags: unix.IFF_UP, want: true},
+		{name: "not present", state: netlink.OperNotPresent, want: true},
+		{name: "dormant", state: netlink.OperDormant, flags: unix.IFF_UP, want: false},
+		{name: "unknown with carrier", state: netlink.OperUnknown, flags: unix.IFF_UP | unix.IFF_LOWER_UP, want: false},
+		{name: "unknown without carrier", state: netlink.OperUnknown, flags: unix.IFF_UP, want: true},
+	}
+
+	for _, tc := range tt {
+		link := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", OperState: tc.state, RawFlags: tc.flags}}
+		if got := linkDown(link); got != tc.want {
+			t.Fatalf("%s: got %v, wanted %v\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNetworkLinkState(t *testing.T) {
+	c := col.Color{}
+	ip := net.ParseIP("2001:db8::1")
+	down := &netlink.Device{LinkAttrs: netlink.LinkAttrs{
+		Name:      "eth0",
+		Index:     2,
+		OperState: netlink.OperDown,
+		RawFlags:  unix.IFF_UP,
+		MTU:       1500,
+	}}
+	tunnel := &netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{
+		Name:      "eth0",
+		Index:     2,
+		OperState: netlink.OperUnknown,
+		RawFlags:  unix.IFF_UP | unix.IFF_LOWER_UP,
+		MTU:       1420,
+	}}
+
+	tt := []struct {
+		name      string
+		n         Network
+		link      netlink.Link
+		wireless  *wirelessInfo
+		wantText  string
+		wantColor string
+	}{
+		{
+			name:      "up",
+			link:      upLink("eth0", 2),
+			wantText:  "eth0 2001:db8::1",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "down",
+			link:      down,
+			wantText:  "eth0 2001:db8::1",
+			wantColor: c.Red(),
+		},
+		{
+			name:      "unknown state with carrier",
+			link:      tunnel,
+			wantText:  "eth0 2001:db8::1",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "state format",
+			n:         Network{Format: "{{.Name}} {{.State}} {{.Carrier}} {{.MTU}} {{.Kind}}"},
+			link:      down,
+			wantText:  "eth0 down false 1500 device",
+			wantColor: c.Red(),
+		},
+		{
+			name:      "tunnel format",
+			n:         Network{Format: "{{.Name}} {{.State}} {{.Carrier}} {{.MTU}} {{.Kind}}"},
+			link:      tunnel,
+			wantText:  "eth0 unknown true 1420 tuntap",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "strong signal",
+			n:         Network{Wireless: true},
+			link:      upLink("eth0", 2),
+			wireless:  &wirelessInfo{connected: true, ssid: "home", signal: -50, quality: 100},
+			wantText:  "eth0 home b100% 2001:db8::1",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "weak signal",
+			n:         Network{Wireless: true},
+			link:      upLink("eth0", 2),
+			wireless:  &wirelessInfo{connected: true, ssid: "home", signal: -75, quality: 35},
+			wantText:  "eth0 home a35% 2001:db8::1",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "critical signal",
+			n:         Network{Wireless: true},
+			link:      upLink("eth0", 2),
+			wireless:  &wirelessInfo{connected: true, ssid: "home", signal: -85, quality: 20},
+			wantText:  "eth0 home a20% 2001:db8::1",
+			wantColor: c.Red(),
+		},
+		{
+			name:      "disconnected",
+			n:         Network{Wireless: true},
+			link:      upLink("eth0", 2),
+			wireless:  &wirelessInfo{signal: -90},
+			wantText:  "eth0 2001:db8::1",
+			wantColor: c.Normal(),
+		},
+	}
+
+	for _, tc := range tt {
+		n := tc.n
+		n.Interfaces = []string{"eth0"}
+		n.SignalWarning, n.SignalCritical = -70, -80
+		n.SignalIcons = []string{"a", "b"}
+		if err := n.compile(); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		n.ifaces = []iface{{exact: true, link: tc.link, ipv6: ip, wireless: tc.wireless}}
+
+		block := printOne(t, tc.name, &n)
+		if block.FullText != tc.wantText {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, block.FullText, tc.wantText)
+		}
+		if block.Color != tc.wantColor {
+			t.Fatalf("%s: got color %q, wanted %q\n", tc.name, block.Color, tc.wantColor)
+		}
+	}
+}
+
+func TestNetworkSubscriptionFailsOnce(t *testing.T) {
+	injected := errors.New("no buffer space available")
+	tt := []struct {
+		name   string
+		report []error
+		want   error
+	}{
+		{name: "error and closed channel", report: []error{injected}, want: injected},
+		{name: "repeated errors", report: []error{injected, errors.New("wrong sender portid")}, want: injected},
+		{name: "closed channel", want: errNetworkSubClosed},
+	}
+
+	for _, tc := range tt {
+		sub := newNetworkSubscription()
+		// Fail the way netlink does: report the error to the callback,
+		// then close the update channel.
+		go func() {
+			for _, err := range tc.report {
+				sub.fail(err)
+			}
+			close(sub.links)
+		}()
+
+		failures := []error{}
+		timeout := time.After(100 * time.Millisecond)
+	loop:
+		for {
+			select {
+			case err := <-sub.failed:
+				failures = append(failures, err)
+			case _, ok := <-sub.links:
+				if !ok {
+					sub.links = nil
+					sub.fail(errNetworkSubClosed)
+				}
+			case <-timeout:
+				break loop
+			}
+		}
+		sub.close()
+
+		if len(failures) != 1 || failures[0] != tc.want {
+			t.Fatalf("%s: got failures %v, wanted [%v]\n", tc.name, failures, tc.want)
+		}
+	}
+}
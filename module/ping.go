@@ -0,0 +1,341 @@
+package module
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	errPingNoTargets     = errors.New("no targets configured")
+	errPingInvalidMethod = errors.New(`method must be "icmp" or "tcp"`)
+)
+
+// pingPayload is sent in ICMP echo requests so they can be recognized in
+// packet captures.
+var pingPayload = []byte("gobar")
+
+// rtt is a round-trip time, configured either as a number of milliseconds or
+// as a duration string, such as "150ms". Unlike other durations, bare numbers
+// aren't seconds since no round-trip time threshold is that long.
+type rtt time.Duration
+
+// rttHook allows rtt fields to be configured as numbers of milliseconds.
+func rttHook(_ reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(rtt(0)) {
+		return data, nil
+	}
+
+	switch v := data.(type) {
+	case int:
+		return rtt(time.Duration(v) * time.Millisecond), nil
+	case float64:
+		return rtt(time.Duration(v * float64(time.Millisecond))), nil
+	case string:
+		d, err := time.ParseDuration(v)
+		return rtt(d), err
+	default:
+		return data, nil
+	}
+}
+
+// icmpEcho sends an ICMP echo request to ip and returns the time until the
+// reply. It uses an unprivileged datagram socket, which requires the group of
+// the process to be in the net.ipv4.ping_group_range sysctl.
+func icmpEcho(ip net.IP, seq uint16, timeout time.Duration) (time.Duration, error) {
+	family, proto := unix.AF_INET, unix.IPPROTO_ICMP
+	var request, reply byte = 8, 0
+	if ip.To4() == nil {
+		family, proto = unix.AF_INET6, unix.IPPROTO_ICMPV6
+		request, reply = 128, 129
+	}
+
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return 0, os.NewSyscallError("socket", err)
+	}
+	f := os.NewFile(uintptr(fd), "icmp")
+	conn, err := net.FilePacketConn(f)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// The kernel fills in the identifier and the checksum, and only
+	// delivers replies with the socket's identifier.
+	msg := make([]byte, 8, 8+len(pingPayload))
+	msg[0] = request
+	binary.BigEndian.PutUint16(msg[6:8], seq)
+	msg = append(msg, pingPayload...)
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.WriteTo(msg, &net.UDPAddr{IP: ip}); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n >= 8 && buf[0] == reply && binary.BigEndian.Uint16(buf[6:8]) == seq {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// tcpConnect returns the time it takes to connect to address.
+func tcpConnect(address string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	conn.Close()
+	return rtt, nil
+}
+
+// probeResult is the outcome of a single probe of a target.
+type probeResult struct {
+	rtt time.Duration
+	err error
+}
+
+// probeWindow holds the results of the most recent probes of a target.
+type probeWindow struct {
+	results []probeResult
+	size    int
+}
+
+func (w *probeWindow) add(result probeResult) {
+	w.results = append(w.results, result)
+	if len(w.results) > w.size {
+		w.results = w.results[len(w.results)-w.size:]
+	}
+}
+
+// stats returns the average round-trip time of the successful probes, the
+// percentage of probes that failed and the error of the last failed probe.
+func (w *probeWindow) stats() (avg time.Duration, loss float64, err error) {
+	if len(w.results) == 0 {
+		return 0, 0, nil
+	}
+
+	var total time.Duration
+	received := 0
+	for _, result := range w.results {
+		if result.err != nil {
+			err = result.err
+			continue
+		}
+		total += result.rtt
+		received++
+	}
+
+	if received > 0 {
+		avg = total / time.Duration(received)
+	}
+	loss = float64(len(w.results)-received) / float64(len(w.results)) * 100
+	return avg, loss, err
+}
+
+// Ping provides the round-trip time and packet loss to targets, probed with
+// ICMP echo requests or TCP connections. The round-trip time is averaged and
+// the packet loss computed over a window of the most recent probes.
+type Ping struct {
+	// Hosts or addresses to probe with ICMP, or "host:port" addresses to
+	// connect to with TCP, for example "1.1.1.1" or "example.com:443".
+	Targets []string `mapstructure:"targets"`
+	// Either "icmp" (the default) or "tcp". Unprivileged ICMP requires the
+	// group of the process to be in the net.ipv4.ping_group_range sysctl.
+	Method string `mapstructure:"method"`
+	// The number of probes per target the statistics are computed over.
+	// Defaults to 10.
+	Window int `mapstructure:"window"`
+	// Round-trip times at which a target is colored yellow and red, as a
+	// number of milliseconds or a duration such as "150ms". Default to 100ms
+	// and 300ms.
+	Warning  rtt `mapstructure:"warning"`
+	Critical rtt `mapstructure:"critical"`
+	// The packet loss percentage at which a target is colored red. Any loss
+	// colors it yellow. Defaults to 50.
+	LossCritical float64 `mapstructure:"loss_critical"`
+	// How long to wait for a reply. Defaults to 2 seconds.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// How often to probe the targets. Defaults to 5 seconds.
+	Interval time.Duration `mapstructure:"interval"`
+
+	windows []probeWindow
+	seq     uint16
+}
+
+// probe probes a single target.
+func (p *Ping) probe(target string, seq uint16) probeResult {
+	if p.Method == "tcp" {
+		rtt, err := tcpConnect(target, p.Timeout)
+		return probeResult{rtt: rtt, err: err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", target)
+	if err != nil {
+		return probeResult{err: err}
+	}
+	rtt, err := icmpEcho(ips[0], seq, p.Timeout)
+	return probeResult{rtt: rtt, err: err}
+}
+
+// probeAll probes all targets concurrently.
+func (p *Ping) probeAll(seq uint16) []probeResult {
+	results := make([]probeResult, len(p.Targets))
+
+	var wg sync.WaitGroup
+	for i, target := range p.Targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i] = p.probe(target, seq)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (p *Ping) print(tx chan []i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:     "ping",
+			Instance: "ping",
+			FullText: fmt.Sprintf("PING: %s", err),
+			Color:    c.Red(),
+		}}
+		return
+	}
+
+	blocks := []i3.Block{}
+	for i, target := range p.Targets {
+		window := p.windows[i]
+		if len(window.results) == 0 {
+			continue
+		}
+		avg, loss, err := window.stats()
+
+		urgent := false
+		color := c.Normal()
+		switch {
+		case loss == 100:
+			color = c.Red()
+			urgent = true
+		case loss >= p.LossCritical || avg >= time.Duration(p.Critical):
+			color = c.Red()
+		case loss > 0 || avg >= time.Duration(p.Warning):
+			color = c.Yellow()
+		}
+
+		short := fmt.Sprintf("%s: %dms", target, avg.Milliseconds())
+		text := fmt.Sprintf("%s: %dms %d%% loss", target, avg.Milliseconds(), int(loss))
+		if loss == 100 {
+			short = fmt.Sprintf("%s: down", target)
+			text = fmt.Sprintf("%s: %s", target, err)
+		}
+
+		blocks = append(blocks, i3.Block{
+			Name:      "ping",
+			Instance:  target,
+			FullText:  text,
+			ShortText: short,
+			Color:     color,
+			Urgent:    urgent,
+		})
+	}
+
+	tx <- blocks
+}
+
+// Run implements Module.
+func (p *Ping) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if len(p.Targets) == 0 {
+		p.print(tx, errPingNoTargets, c)
+		return
+	}
+	if p.Method == "" {
+		p.Method = "icmp"
+	}
+	if p.Method != "icmp" && p.Method != "tcp" {
+		p.print(tx, errPingInvalidMethod, c)
+		return
+	}
+	if p.Window <= 0 {
+		p.Window = 10
+	}
+	if p.Warning <= 0 {
+		p.Warning = rtt(100 * time.Millisecond)
+	}
+	if p.Critical <= 0 {
+		p.Critical = rtt(300 * time.Millisecond)
+	}
+	if p.LossCritical == 0 {
+		p.LossCritical = 50
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = 2 * time.Second
+	}
+	if p.Interval <= 0 {
+		p.Interval = 5 * time.Second
+	}
+
+	p.windows = make([]probeWindow, len(p.Targets))
+	for i := range p.windows {
+		p.windows[i].size = p.Window
+	}
+
+	ready := make(chan struct{}, 1)
+	results := make(chan []probeResult)
+	defer func() {
+		close(ready)
+		close(results)
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	for {
+		select {
+		// no click support for ping
+		case <-rx:
+		case <-ready:
+			// Probes can take up to the timeout, so they are sent in the
+			// background to keep handling clicks.
+			p.seq++
+			go func(seq uint16) {
+				results <- p.probeAll(seq)
+				time.Sleep(p.Interval)
+				ready <- struct{}{}
+			}(p.seq)
+		case probed := <-results:
+			for i, result := range probed {
+				p.windows[i].add(result)
+			}
+			p.print(tx, nil, c)
+		}
+	}
+}
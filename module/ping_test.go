@@ -0,0 +1,170 @@
+package module
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestProbeWindow(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	tt := []struct {
+		name     string
+		size     int
+		results  []probeResult
+		wantAvg  time.Duration
+		wantLoss float64
+	}{
+		{
+			name:    "no probes",
+			size:    3,
+			results: nil,
+		},
+		{
+			name: "all received",
+			size: 3,
+			results: []probeResult{
+				{rtt: 10 * time.Millisecond},
+				{rtt: 20 * time.Millisecond},
+			},
+			wantAvg: 15 * time.Millisecond,
+		},
+		{
+			name: "some lost",
+			size: 4,
+			results: []probeResult{
+				{rtt: 10 * time.Millisecond},
+				{err: errTimeout},
+				{rtt: 30 * time.Millisecond},
+				{err: errTimeout},
+			},
+			wantAvg:  20 * time.Millisecond,
+			wantLoss: 50,
+		},
+		{
+			name: "losses slide out of the window",
+			size: 2,
+			results: []probeResult{
+				{err: errTimeout},
+				{err: errTimeout},
+				{rtt: 10 * time.Millisecond},
+				{rtt: 30 * time.Millisecond},
+			},
+			wantAvg: 20 * time.Millisecond,
+		},
+		{
+			name: "all lost",
+			size: 2,
+			results: []probeResult{
+				{err: errTimeout},
+				{err: errTimeout},
+			},
+			wantLoss: 100,
+		},
+	}
+
+	for _, tc := range tt {
+		w := probeWindow{size: tc.size}
+		for _, result := range tc.results {
+			w.add(result)
+		}
+		avg, loss, _ := w.stats()
+		if avg != tc.wantAvg || loss != tc.wantLoss {
+			t.Fatalf("%s: got %s %f%%, wanted %s %f%%\n", tc.name, avg, loss, tc.wantAvg, tc.wantLoss)
+		}
+	}
+}
+
+func TestPingTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// A port that was just closed is very unlikely to be reused during the
+	// test.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	p := &Ping{
+		Targets: []string{ln.Addr().String(), closedAddr},
+		Method:  "tcp",
+		Timeout: time.Second,
+	}
+	results := p.probeAll(1)
+	if results[0].err != nil {
+		t.Fatalf("%s: got %v, wanted a connection\n", p.Targets[0], results[0].err)
+	}
+	if results[1].err == nil {
+		t.Fatalf("%s: got a connection, wanted an error\n", p.Targets[1])
+	}
+}
+
+func TestPingICMP(t *testing.T) {
+	p := &Ping{
+		Targets: []string{"127.0.0.1", "::1"},
+		Method:  "icmp",
+		Timeout: time.Second,
+	}
+	for i, result := range p.probeAll(1) {
+		// Unprivileged ICMP sockets can be disabled with the
+		// net.ipv4.ping_group_range sysctl, and IPv6 can be disabled.
+		if errors.Is(result.err, os.ErrPermission) || errors.Is(result.err, unix.EAFNOSUPPORT) {
+			t.Skipf("%s: %v\n", p.Targets[i], result.err)
+		}
+		if result.err != nil {
+			t.Fatalf("%s: got %v, wanted a reply\n", p.Targets[i], result.err)
+		}
+	}
+}
+
+func TestPingDecodeRTT(t *testing.T) {
+	tt := []struct {
+		name         string
+		config       map[string]any
+		wantWarning  time.Duration
+		wantCritical time.Duration
+	}{
+		{
+			name:         "milliseconds",
+			config:       map[string]any{"warning": 150, "critical": 400.5},
+			wantWarning:  150 * time.Millisecond,
+			wantCritical: 400500 * time.Microsecond,
+		},
+		{
+			name:         "durations",
+			config:       map[string]any{"warning": "150ms", "critical": "1s"},
+			wantWarning:  150 * time.Millisecond,
+			wantCritical: time.Second,
+		},
+	}
+
+	for _, tc := range tt {
+		var p Ping
+		if err := decode(tc.config, &p); err != nil {
+			t.Fatalf("%s: %v\n", tc.name, err)
+		}
+		if time.Duration(p.Warning) != tc.wantWarning || time.Duration(p.Critical) != tc.wantCritical {
+			t.Fatalf("%s: got %s and %s, wanted %s and %s\n", tc.name,
+				time.Duration(p.Warning), time.Duration(p.Critical), tc.wantWarning, tc.wantCritical)
+		}
+	}
+}
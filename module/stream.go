@@ -0,0 +1,253 @@
+package module
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+const streamMinBackoff = time.Second
+
+// streamMaxLine is the longest line a command can print.
+const streamMaxLine = 1024 * 1024
+
+// Stream is a module that runs a long-running command and prints each line it
+// writes to stdout, either as plain text or as a JSON object with the fields
+// of an i3bar block. Click events are written to the command's stdin as JSON
+// lines. The command is restarted with an exponential backoff when it exits.
+// Only works on Linux.
+type Stream struct {
+	// The command to run with "sh -c".
+	Command string `mapstructure:"command"`
+	// Passed to the command as BLOCK_NAME and BLOCK_INSTANCE.
+	Name     string `mapstructure:"name"`
+	Instance string `mapstructure:"instance"`
+	// The markup of the block if the command doesn't set one, for example
+	// "pango".
+	Markup string `mapstructure:"markup"`
+	// The maximum time to wait before restarting the command. Defaults to 1
+	// minute.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// streamProcess is a running instance of the command.
+type streamProcess struct {
+	proc    *exec.Cmd
+	clicks  chan []byte
+	started time.Time
+}
+
+func (s *Stream) label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "stream"
+}
+
+// start runs the command, sending each line it prints on lines and the
+// result of the command on exited once stdout is closed, until done is
+// closed.
+func (s *Stream) start(lines chan<- string, exited chan<- error, done <-chan struct{}) (*streamProcess, error) {
+	proc := exec.Command("sh", "-c", s.Command)
+	proc.Env = clickEnv(s.label(), s.Instance, nil)
+	proc.Stderr = os.Stderr
+	proc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdin, err := proc.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := proc.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := proc.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &streamProcess{
+		proc:    proc,
+		clicks:  make(chan []byte, 16),
+		started: time.Now(),
+	}
+
+	// Write clicks from a separate goroutine so that a command that doesn't
+	// read its stdin can't block the module.
+	go func(w io.WriteCloser) {
+		defer w.Close()
+		for data := range p.clicks {
+			if _, err := w.Write(data); err != nil {
+				log.Printf("failed to write click event to %s: %v", s.label(), err)
+			}
+		}
+	}(stdin)
+
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), streamMaxLine)
+	scan:
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				break scan
+			}
+		}
+		// The command blocks writing to stdout once it is no longer read,
+		// such as after a line that is too long, so it is killed to be
+		// restarted.
+		scanErr := scanner.Err()
+		if scanErr != nil {
+			_ = syscall.Kill(-proc.Process.Pid, syscall.SIGKILL)
+		}
+		err := proc.Wait()
+		if scanErr != nil {
+			err = scanErr
+		}
+		select {
+		case exited <- err:
+		case <-done:
+		}
+	}()
+
+	return p, nil
+}
+
+func (s *Stream) print(tx chan []i3.Block, block *i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:      "stream",
+			Instance:  s.Instance,
+			FullText:  fmt.Sprintf("%s: %s", s.label(), err),
+			ShortText: fmt.Sprintf("%s: error", s.label()),
+			Color:     c.Red(),
+		}}
+		return
+	}
+
+	if block == nil {
+		tx <- []i3.Block{}
+		return
+	}
+
+	block.Name = "stream"
+	if s.Instance != "" {
+		block.Instance = s.Instance
+	}
+	if block.Color == "" {
+		block.Color = c.Normal()
+	}
+	if block.Markup == "" {
+		block.Markup = s.Markup
+	}
+
+	tx <- []i3.Block{*block}
+}
+
+// nextStreamBackoff doubles the time to wait before restarting the command, up
+// to max.
+func nextStreamBackoff(backoff, max time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// Run implements Module.
+func (s *Stream) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if s.Command == "" {
+		s.print(tx, nil, errCommandEmpty, c)
+		return
+	}
+
+	if s.MaxBackoff < streamMinBackoff {
+		s.MaxBackoff = time.Minute
+	}
+
+	lines := make(chan string)
+	exited := make(chan error)
+	ready := make(chan struct{}, 1)
+	done := make(chan struct{})
+
+	var current *streamProcess
+	defer func() {
+		close(done)
+		if current != nil {
+			_ = syscall.Kill(-current.proc.Process.Pid, syscall.SIGTERM)
+			close(current.clicks)
+		}
+	}()
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	backoff := streamMinBackoff
+	restart := func(err error) {
+		s.print(tx, nil, fmt.Errorf("%v, restarting in %s", err, backoff), c)
+		go func(wait time.Duration) {
+			select {
+			case <-time.After(wait):
+				ready <- struct{}{}
+			case <-done:
+			}
+		}(backoff)
+		backoff = nextStreamBackoff(backoff, s.MaxBackoff)
+	}
+
+	for {
+		select {
+		case click, ok := <-rx:
+			// The click channel is closed when the bar exits.
+			if !ok {
+				return
+			}
+			if current == nil {
+				continue
+			}
+			data, err := json.Marshal(click)
+			if err != nil {
+				log.Printf("failed to marshal click event: %v", err)
+				continue
+			}
+			select {
+			case current.clicks <- append(data, '\n'):
+			default:
+				log.Printf("dropping click event, %s is not reading stdin", s.label())
+			}
+		case line := <-lines:
+			block, err := parseCommandOutput([]byte(line))
+			s.print(tx, block, err, c)
+		case err := <-exited:
+			close(current.clicks)
+			// Consider a command that ran for a while to be healthy again.
+			if time.Since(current.started) > s.MaxBackoff {
+				backoff = streamMinBackoff
+			}
+			current = nil
+			if err == nil {
+				err = errors.New("exited")
+			}
+			restart(err)
+		case <-ready:
+			p, err := s.start(lines, exited, done)
+			if err != nil {
+				restart(err)
+				continue
+			}
+			current = p
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package module
+
+import (
+	"testing"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+func TestNextStreamBackoff(t *testing.T) {
+	tt := []struct {
+		name    string
+		backoff time.Duration
+		max     time.Duration
+		want    time.Duration
+	}{
+		{name: "doubles", backoff: time.Second, max: time.Minute, want: 2 * time.Second},
+		{name: "doubles again", backoff: 16 * time.Second, max: time.Minute, want: 32 * time.Second},
+		{name: "capped", backoff: 32 * time.Second, max: time.Minute, want: time.Minute},
+		{name: "at max", backoff: time.Minute, max: time.Minute, want: time.Minute},
+	}
+
+	for _, tc := range tt {
+		if got := nextStreamBackoff(tc.backoff, tc.max); got != tc.want {
+			t.Fatalf("%s: got %s, wanted %s\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStreamRun(t *testing.T) {
+	c := col.Color{}
+	tt := []struct {
+		name   string
+		stream Stream
+		clicks []i3.ClickEvent
+		want   []i3.Block
+	}{
+		{
+			name:   "empty command",
+			stream: Stream{},
+			want: []i3.Block{
+				{Name: "stream", FullText: "stream: no command configured", ShortText: "stream: error", Color: c.Red()},
+			},
+		},
+		{
+			name:   "plain and json lines",
+			stream: Stream{Command: `echo "$BLOCK_NAME up"; echo '{"full_text": "json", "color": "#ff0000"}'; echo; sleep 1`, Name: "vpn", Instance: "wg0"},
+			want: []i3.Block{
+				{Name: "stream", Instance: "wg0", FullText: "vpn up", Color: c.Normal()},
+				{Name: "stream", Instance: "wg0", FullText: "json", Color: "#ff0000"},
+				{Name: "stream", Instance: "wg0"},
+			},
+		},
+		{
+			name:   "clicks on stdin",
+			stream: Stream{Command: `echo ready; read click; echo "$click" | grep -o '"button":[0-9]*'; sleep 1`, Markup: "pango"},
+			clicks: []i3.ClickEvent{{Button: i3.RightClick}},
+			want: []i3.Block{
+				{Name: "stream", FullText: "ready", Color: c.Normal(), Markup: "pango"},
+				{Name: "stream", FullText: `"button":3`, Color: c.Normal(), Markup: "pango"},
+			},
+		},
+		{
+			name:   "line too long",
+			stream: Stream{Command: `head -c 2000000 /dev/zero | tr '\0' x; echo; sleep 10`},
+			want: []i3.Block{
+				{Name: "stream", FullText: "stream: bufio.Scanner: token too long, restarting in 1s", ShortText: "stream: error", Color: c.Red()},
+			},
+		},
+		{
+			name:   "restart on exit",
+			stream: Stream{Command: "echo once; exit 3"},
+			want: []i3.Block{
+				{Name: "stream", FullText: "once", Color: c.Normal()},
+				{Name: "stream", FullText: "stream: exit status 3, restarting in 1s", ShortText: "stream: error", Color: c.Red()},
+				{Name: "stream", FullText: "once", Color: c.Normal()},
+				{Name: "stream", FullText: "stream: exit status 3, restarting in 2s", ShortText: "stream: error", Color: c.Red()},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		tx := make(chan []i3.Block)
+		rx := make(chan i3.ClickEvent, len(tc.clicks))
+		s := tc.stream
+		stopped := make(chan struct{})
+		go func() {
+			s.Run(tx, rx, c)
+			close(stopped)
+		}()
+
+		for i, want := range tc.want {
+			var blocks []i3.Block
+			select {
+			case blocks = <-tx:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: timed out waiting for %+v\n", tc.name, want)
+			}
+
+			// A line without text hides the block.
+			if want.FullText == "" {
+				if len(blocks) != 0 {
+					t.Fatalf("%s: got %+v, wanted no blocks\n", tc.name, blocks)
+				}
+				continue
+			}
+			if len(blocks) != 1 || blocks[0] != want {
+				t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, blocks, want)
+			}
+
+			// Clicks are sent once the command is known to be running.
+			if i == 0 {
+				for _, click := range tc.clicks {
+					rx <- click
+				}
+			}
+		}
+
+		// Closing the click channel stops the module and kills the command.
+		close(rx)
+		for stoppedRun := false; !stoppedRun; {
+			select {
+			case <-tx:
+			case <-stopped:
+				stoppedRun = true
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: timed out waiting for the module to stop\n", tc.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package module
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+)
+
+var errTemperatureNoSensors = errors.New("no matching sensors")
+
+// sensor is a temperature or fan sensor exposed by the kernel.
+type sensor struct {
+	name string
+	// The file holding the current value, in millidegrees Celsius for
+	// temperatures or RPM for fans.
+	input string
+	fan   bool
+	// The critical temperature reported by the kernel in degrees Celsius, or
+	// zero if there is none.
+	crit  float64
+	value float64
+	// The error of the last read, for example ENODATA for the sensor of a
+	// wireless card that is down.
+	err error
+}
+
+// readSysfsFloat reads a file holding a single number, as is common in sysfs.
+func readSysfsFloat(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(bytes.TrimSpace(data)), 64)
+}
+
+// readSysfsString reads a file holding a single value, as is common in sysfs.
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(data))
+}
+
+// thermalSensors discovers the thermal zones under /sys/class/thermal. Zones
+// are named by their type, for example "x86_pkg_temp" or "acpitz".
+func thermalSensors() []sensor {
+	zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+
+	sensors := []sensor{}
+	for _, zone := range zones {
+		s := sensor{
+			name:  readSysfsString(filepath.Join(zone, "type")),
+			input: filepath.Join(zone, "temp"),
+		}
+
+		trips, _ := filepath.Glob(filepath.Join(zone, "trip_point_*_type"))
+		for _, trip := range trips {
+			if readSysfsString(trip) != "critical" {
+				continue
+			}
+			if crit, err := readSysfsFloat(strings.TrimSuffix(trip, "_type") + "_temp"); err == nil {
+				s.crit = crit / 1000
+			}
+		}
+
+		sensors = append(sensors, s)
+	}
+	return sensors
+}
+
+// hwmonSensors discovers the temperature and fan sensors under
+// /sys/class/hwmon. Sensors are named by the chip name and the sensor's label,
+// for example "coretemp/Package id 0" or "thinkpad/fan1".
+func hwmonSensors() []sensor {
+	chips, _ := filepath.Glob("/sys/class/hwmon/hwmon*")
+
+	sensors := []sensor{}
+	for _, chip := range chips {
+		chipName := readSysfsString(filepath.Join(chip, "name"))
+
+		inputs, _ := filepath.Glob(filepath.Join(chip, "temp*_input"))
+		fans, _ := filepath.Glob(filepath.Join(chip, "fan*_input"))
+		for _, input := range append(inputs, fans...) {
+			prefix := strings.TrimSuffix(input, "_input")
+			label := readSysfsString(prefix + "_label")
+			if label == "" {
+				label = filepath.Base(prefix)
+			}
+
+			s := sensor{
+				name:  chipName + "/" + label,
+				input: input,
+				fan:   strings.HasPrefix(filepath.Base(prefix), "fan"),
+			}
+			if crit, err := readSysfsFloat(prefix + "_crit"); err == nil && !s.fan {
+				s.crit = crit / 1000
+			}
+
+			sensors = append(sensors, s)
+		}
+	}
+	return sensors
+}
+
+// Temperature provides temperatures and fan speeds from the kernel's thermal
+// zones and hwmon sensors. Sensors can be selected with a regexp matched on
+// their name, such as "coretemp/Package id 0", "acpitz" or "thinkpad/fan1".
+// Only works on Linux.
+type Temperature struct {
+	Pattern *string
+	// Whether to show each sensor in its own block instead of the highest
+	// temperature.
+	PerSensor bool `mapstructure:"per_sensor"`
+	// Whether to include fan speeds.
+	Fans bool `mapstructure:"fans"`
+	// The number of degrees below the kernel's critical temperature at which
+	// the block is colored yellow. Defaults to 15.
+	WarningMargin float64 `mapstructure:"warning_margin"`
+	// Temperatures at which the block is colored yellow and red for sensors
+	// without a critical temperature. Default to 70 and 90.
+	Warning  float64 `mapstructure:"warning"`
+	Critical float64 `mapstructure:"critical"`
+	// How often to read the sensors. Defaults to 5 seconds.
+	Interval time.Duration `mapstructure:"interval"`
+
+	patternRe *regexp.Regexp
+	sensors   []sensor
+}
+
+func (t *Temperature) init() error {
+	if t.Pattern != nil {
+		var err error
+		t.patternRe, err = regexp.Compile(*t.Pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.sensors = t.selectSensors(append(thermalSensors(), hwmonSensors()...))
+	if len(t.sensors) == 0 {
+		return errTemperatureNoSensors
+	}
+
+	return nil
+}
+
+// selectSensors returns the sensors matching the pattern, leaving out fans
+// unless they are shown.
+func (t *Temperature) selectSensors(all []sensor) []sensor {
+	sensors := []sensor{}
+	for _, s := range all {
+		if s.fan && !t.Fans {
+			continue
+		}
+		if t.patternRe != nil && !t.patternRe.MatchString(s.name) {
+			continue
+		}
+		sensors = append(sensors, s)
+	}
+	return sensors
+}
+
+// severity returns 2 if the sensor is at its critical temperature, 1 if it is
+// close to it and 0 otherwise.
+func (t *Temperature) severity(s sensor) int {
+	if s.fan {
+		return 0
+	}
+
+	warning, critical := t.Warning, t.Critical
+	if s.crit > 0 {
+		warning, critical = s.crit-t.WarningMargin, s.crit
+	}
+
+	switch {
+	case s.value >= critical:
+		return 2
+	case s.value >= warning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (t *Temperature) color(severity int, c col.Color) string {
+	switch severity {
+	case 2:
+		return c.Red()
+	case 1:
+		return c.Yellow()
+	default:
+		return c.Normal()
+	}
+}
+
+func formatSensor(s sensor) string {
+	if s.fan {
+		return fmt.Sprintf("%d RPM", int(s.value))
+	}
+	return fmt.Sprintf("%d°C", int(s.value))
+}
+
+func (t *Temperature) print(tx chan []i3.Block, err error, c col.Color) {
+	if err != nil {
+		tx <- []i3.Block{{
+			Name:     "temperature",
+			Instance: "temperature",
+			FullText: fmt.Sprintf("TEMP: %s", err),
+			Color:    c.Red(),
+		}}
+		return
+	}
+
+	blocks := []i3.Block{}
+
+	if t.PerSensor {
+		for _, s := range t.sensors {
+			if s.err != nil {
+				blocks = append(blocks, i3.Block{
+					Name:      "temperature",
+					Instance:  s.name,
+					FullText:  fmt.Sprintf("%s: n/a", s.name),
+					ShortText: "n/a",
+					Color:     c.Normal(),
+				})
+				continue
+			}
+			severity := t.severity(s)
+			blocks = append(blocks, i3.Block{
+				Name:      "temperature",
+				Instance:  s.name,
+				FullText:  fmt.Sprintf("%s: %s", s.name, formatSensor(s)),
+				ShortText: formatSensor(s),
+				Color:     t.color(severity, c),
+				Urgent:    severity == 2,
+			})
+		}
+		tx <- blocks
+		return
+	}
+
+	var hottest, fastest *sensor
+	severity := 0
+	for i, s := range t.sensors {
+		if s.err != nil {
+			continue
+		}
+		if s.fan {
+			if fastest == nil || s.value > fastest.value {
+				fastest = &t.sensors[i]
+			}
+			continue
+		}
+		if hottest == nil || s.value > hottest.value {
+			hottest = &t.sensors[i]
+		}
+		if sev := t.severity(s); sev > severity {
+			severity = sev
+		}
+	}
+
+	parts := []string{}
+	if hottest != nil {
+		parts = append(parts, formatSensor(*hottest))
+	}
+	if fastest != nil {
+		parts = append(parts, formatSensor(*fastest))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "n/a")
+	}
+	text := "TEMP: " + strings.Join(parts, " ")
+
+	tx <- []i3.Block{{
+		Name:      "temperature",
+		Instance:  "temperature",
+		FullText:  text,
+		ShortText: text,
+		Color:     t.color(severity, c),
+		Urgent:    severity == 2,
+	}}
+}
+
+// read reads the sensors. A sensor that can't be read is only left out until
+// it can be read again, since some drivers fail to report a value while the
+// device is down.
+func (t *Temperature) read() {
+	for i, s := range t.sensors {
+		value, err := readSysfsFloat(s.input)
+		t.sensors[i].err = err
+		if err != nil {
+			continue
+		}
+		if !s.fan {
+			value /= 1000
+		}
+		t.sensors[i].value = value
+	}
+}
+
+// Run implements Module.
+func (t *Temperature) Run(tx chan []i3.Block, rx chan i3.ClickEvent, c col.Color) {
+	if t.WarningMargin == 0 {
+		t.WarningMargin = 15
+	}
+	if t.Warning == 0 {
+		t.Warning = 70
+	}
+	if t.Critical == 0 {
+		t.Critical = 90
+	}
+	if t.Interval <= 0 {
+		t.Interval = 5 * time.Second
+	}
+
+	if err := t.init(); err != nil {
+		t.print(tx, err, c)
+		return
+	}
+
+	ready := make(chan struct{}, 1)
+	defer close(ready)
+
+	go func() {
+		ready <- struct{}{}
+	}()
+
+	for {
+		select {
+		// no click support for temperature
+		case <-rx:
+		case <-ready:
+			go func() {
+				time.Sleep(t.Interval)
+				ready <- struct{}{}
+			}()
+
+			t.read()
+			t.print(tx, nil, c)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"golang.org/x/exp/slices"
+)
+
+func TestTemperatureRead(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "temp1_input"), []byte("54000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fan1_input"), []byte("2100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	temp := &Temperature{sensors: []sensor{
+		{name: "coretemp/Core 0", input: filepath.Join(dir, "temp1_input")},
+		{name: "iwlwifi_1/temp1", input: filepath.Join(dir, "missing")},
+		{name: "thinkpad/fan1", input: filepath.Join(dir, "fan1_input"), fan: true},
+	}}
+	temp.read()
+
+	tt := []struct {
+		name      string
+		wantValue float64
+		wantErr   bool
+	}{
+		{name: "coretemp/Core 0", wantValue: 54},
+		{name: "iwlwifi_1/temp1", wantErr: true},
+		{name: "thinkpad/fan1", wantValue: 2100},
+	}
+
+	for i, tc := range tt {
+		s := temp.sensors[i]
+		if (s.err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, wanted error %v\n", tc.name, s.err, tc.wantErr)
+		}
+		if s.value != tc.wantValue {
+			t.Fatalf("%s: got %v, wanted %v\n", tc.name, s.value, tc.wantValue)
+		}
+	}
+}
+
+func TestTemperatureSeverity(t *testing.T) {
+	temp := &Temperature{WarningMargin: 15, Warning: 70, Critical: 90}
+	tt := []struct {
+		name   string
+		sensor sensor
+		want   int
+	}{
+		{name: "cool", sensor: sensor{value: 60}, want: 0},
+		{name: "warning", sensor: sensor{value: 75}, want: 1},
+		{name: "critical", sensor: sensor{value: 90}, want: 2},
+		{name: "below margin of crit", sensor: sensor{value: 84, crit: 100}, want: 0},
+		{name: "within margin of crit", sensor: sensor{value: 85, crit: 100}, want: 1},
+		{name: "above default critical but below crit", sensor: sensor{value: 95, crit: 100}, want: 1},
+		{name: "at crit", sensor: sensor{value: 100, crit: 100}, want: 2},
+		{name: "low crit", sensor: sensor{value: 66, crit: 80}, want: 1},
+		{name: "above low crit", sensor: sensor{value: 81, crit: 80}, want: 2},
+		{name: "fan", sensor: sensor{value: 5000, fan: true}, want: 0},
+	}
+
+	for _, tc := range tt {
+		if got := temp.severity(tc.sensor); got != tc.want {
+			t.Fatalf("%s: got %d, wanted %d\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTemperatureSelectSensors(t *testing.T) {
+	all := []sensor{
+		{name: "acpitz"},
+		{name: "coretemp/Package id 0"},
+		{name: "coretemp/Core 0"},
+		{name: "thinkpad/fan1", fan: true},
+		{name: "thinkpad/CPU"},
+	}
+	pattern := func(s string) *string { return &s }
+
+	tt := []struct {
+		name    string
+		pattern *string
+		fans    bool
+		want    []string
+	}{
+		{
+			name: "all temperatures",
+			want: []string{"acpitz", "coretemp/Package id 0", "coretemp/Core 0", "thinkpad/CPU"},
+		},
+		{
+			name: "with fans",
+			fans: true,
+			want: []string{"acpitz", "coretemp/Package id 0", "coretemp/Core 0", "thinkpad/fan1", "thinkpad/CPU"},
+		},
+		{
+			name:    "pattern",
+			pattern: pattern("^coretemp/"),
+			want:    []string{"coretemp/Package id 0", "coretemp/Core 0"},
+		},
+		{
+			name:    "pattern matching a fan that isn't shown",
+			pattern: pattern("^thinkpad/"),
+			want:    []string{"thinkpad/CPU"},
+		},
+		{
+			name:    "pattern with fans",
+			pattern: pattern("^thinkpad/"),
+			fans:    true,
+			want:    []string{"thinkpad/fan1", "thinkpad/CPU"},
+		},
+		{
+			name:    "no match",
+			pattern: pattern("nvme"),
+			want:    []string{},
+		},
+	}
+
+	for _, tc := range tt {
+		temp := &Temperature{Pattern: tc.pattern, Fans: tc.fans}
+		if tc.pattern != nil {
+			temp.patternRe = regexp.MustCompile(*tc.pattern)
+		}
+		got := []string{}
+		for _, s := range temp.selectSensors(all) {
+			got = append(got, s.name)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTemperaturePrint(t *testing.T) {
+	c := col.Color{}
+	tt := []struct {
+		name      string
+		perSensor bool
+		sensors   []sensor
+		want      []i3.Block
+	}{
+		{
+			name: "hottest with fan",
+			sensors: []sensor{
+				{name: "acpitz", value: 50},
+				{name: "coretemp/Core 0", value: 62},
+				{name: "thinkpad/fan1", value: 2100, fan: true},
+				{name: "thinkpad/fan2", value: 3400, fan: true},
+			},
+			want: []i3.Block{{FullText: "TEMP: 62°C 3400 RPM", ShortText: "TEMP: 62°C 3400 RPM", Color: c.Normal()}},
+		},
+		{
+			name: "color of the most severe sensor",
+			sensors: []sensor{
+				{name: "BAT0", value: 60, crit: 60},
+				{name: "coretemp/Core 0", value: 75},
+			},
+			want: []i3.Block{{FullText: "TEMP: 75°C", ShortText: "TEMP: 75°C", Color: c.Red(), Urgent: true}},
+		},
+		{
+			name: "only fans",
+			sensors: []sensor{
+				{name: "thinkpad/fan1", value: 0, fan: true},
+			},
+			want: []i3.Block{{FullText: "TEMP: 0 RPM", ShortText: "TEMP: 0 RPM", Color: c.Normal()}},
+		},
+		{
+			name: "unreadable",
+			sensors: []sensor{
+				{name: "iwlwifi_1/temp1", err: os.ErrNotExist},
+			},
+			want: []i3.Block{{FullText: "TEMP: n/a", ShortText: "TEMP: n/a", Color: c.Normal()}},
+		},
+		{
+			name:      "per sensor",
+			perSensor: true,
+			sensors: []sensor{
+				{name: "coretemp/Core 0", value: 86, crit: 100},
+				{name: "thinkpad/fan1", value: 2100, fan: true},
+				{name: "iwlwifi_1/temp1", err: os.ErrNotExist},
+			},
+			want: []i3.Block{
+				{Instance: "coretemp/Core 0", FullText: "coretemp/Core 0: 86°C", ShortText: "86°C", Color: c.Yellow()},
+				{Instance: "thinkpad/fan1", FullText: "thinkpad/fan1: 2100 RPM", ShortText: "2100 RPM", Color: c.Normal()},
+				{Instance: "iwlwifi_1/temp1", FullText: "iwlwifi_1/temp1: n/a", ShortText: "n/a", Color: c.Normal()},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		temp := &Temperature{
+			PerSensor:     tc.perSensor,
+			Fans:          true,
+			WarningMargin: 15,
+			Warning:       70,
+			Critical:      90,
+			sensors:       tc.sensors,
+		}
+		tx := make(chan []i3.Block, 1)
+		temp.print(tx, nil, c)
+		got := <-tx
+
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d blocks, wanted %d\n", tc.name, len(got), len(tc.want))
+		}
+		for i, want := range tc.want {
+			want.Name = "temperature"
+			if want.Instance == "" {
+				want.Instance = "temperature"
+			}
+			if !reflect.DeepEqual(got[i], want) {
+				t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got[i], want)
+			}
+		}
+	}
+}
@@ -13,11 +13,11 @@ type Text struct {
 // Run implements Module.
 func (t *Text) Run(tx chan []i3.Block, _ chan i3.ClickEvent, c col.Color) {
 	tx <- []i3.Block{{
-		Name:      "text",
-		Instance:  t.Content,
-		FullText:  t.Content,
-		ShortText: t.Content,
-		MinWidth:  len(t.Content),
-		Color:     c.Normal(),
+		Name:         "text",
+		Instance:     t.Content,
+		FullText:     t.Content,
+		ShortText:    t.Content,
+		MinWidthText: t.Content,
+		Color:        c.Normal(),
 	}}
 }
@@ -0,0 +1,187 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errTrafficNoStateDir = errors.New("neither XDG_STATE_HOME nor HOME are set")
+
+// byteSize is a number of bytes, configured either as a number or as a
+// string with a unit, such as "500MB" or "10GiB".
+type byteSize float64
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"kB":  1e3,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// parseByteSize parses a number of bytes with an optional unit.
+func parseByteSize(s string) (byteSize, error) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if idx < 0 {
+		idx = len(s)
+	}
+
+	n, err := strconv.ParseFloat(s[:idx], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	unit, ok := byteUnits[strings.TrimSpace(s[idx:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+	return byteSize(n * unit), nil
+}
+
+// byteSizeHook allows byteSize fields to be configured as strings with a
+// unit.
+func byteSizeHook(_ reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(byteSize(0)) {
+		return data, nil
+	}
+
+	if s, ok := data.(string); ok {
+		return parseByteSize(s)
+	}
+	return data, nil
+}
+
+// trafficCounter accumulates the traffic of an interface over a billing
+// period from the kernel's link statistics.
+type trafficCounter struct {
+	// bytes received and transmitted during the period
+	RX uint64 `json:"rx"`
+	TX uint64 `json:"tx"`
+	// start of the period the bytes were counted in
+	PeriodStart time.Time `json:"period_start"`
+
+	// link statistics of the last sample, which are only comparable to the
+	// next sample for the same link during the same boot
+	LastRX uint64 `json:"last_rx"`
+	LastTX uint64 `json:"last_tx"`
+	Index  int    `json:"index"`
+	BootID string `json:"boot_id"`
+}
+
+// update adds the traffic since the last sample to the counter, starting
+// over if the billing period has changed.
+func (t *trafficCounter) update(rx, tx uint64, index int, bootID string, periodStart time.Time) {
+	if !t.PeriodStart.Equal(periodStart) {
+		t.RX, t.TX = 0, 0
+		t.PeriodStart = periodStart
+	}
+
+	// The first sample of a new counter is only a baseline.
+	if t.Index == 0 {
+		t.LastRX, t.LastTX = rx, tx
+		t.Index, t.BootID = index, bootID
+		return
+	}
+
+	// The link statistics start from zero after a reboot, when a link is
+	// recreated or when a driver is reloaded.
+	lastRX, lastTX := t.LastRX, t.LastTX
+	if t.BootID != bootID || t.Index != index || rx < lastRX || tx < lastTX {
+		lastRX, lastTX = 0, 0
+	}
+
+	t.RX += rx - lastRX
+	t.TX += tx - lastTX
+	t.LastRX, t.LastTX = rx, tx
+	t.Index, t.BootID = index, bootID
+}
+
+// total returns the bytes received and transmitted during the period.
+func (t *trafficCounter) total() float64 {
+	return float64(t.RX + t.TX)
+}
+
+// billingPeriodStart returns the start of the billing period containing now,
+// for periods starting at midnight on the given day of each month. Days past
+// the end of a month start the period on its last day.
+func billingPeriodStart(now time.Time, day int) time.Time {
+	start := func(year int, month time.Month) time.Time {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, now.Location()).Day()
+		if day > last {
+			return time.Date(year, month, last, 0, 0, 0, 0, now.Location())
+		}
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	}
+
+	if s := start(now.Year(), now.Month()); !now.Before(s) {
+		return s
+	}
+	// time.Date normalizes month 0 to December of the previous year.
+	prev := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
+	return start(prev.Year(), prev.Month())
+}
+
+// trafficStateDir returns the directory the traffic counters are persisted
+// in, $XDG_STATE_HOME/gobar/traffic.
+func trafficStateDir() (string, error) {
+	if dir, ok := os.LookupEnv("XDG_STATE_HOME"); ok && dir != "" {
+		return filepath.Join(dir, "gobar", "traffic"), nil
+	}
+	if home, ok := os.LookupEnv("HOME"); ok && home != "" {
+		return filepath.Join(home, ".local", "state", "gobar", "traffic"), nil
+	}
+	return "", errTrafficNoStateDir
+}
+
+// loadTrafficCounter reads a persisted counter, returning an empty counter if
+// there is none yet.
+func loadTrafficCounter(path string) (*trafficCounter, error) {
+	t := &trafficCounter{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// save persists the counter, replacing the file atomically so that a crash
+// can't leave a truncated counter behind.
+func (t *trafficCounter) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readBootID returns the kernel's random identifier of the current boot.
+func readBootID() string {
+	return readSysfsString("/proc/sys/kernel/random/boot_id")
+}
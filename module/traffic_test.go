@@ -0,0 +1,253 @@
+package module
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"github.com/vishvananda/netlink"
+)
+
+func TestTrafficCounterUpdate(t *testing.T) {
+	march := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	type sample struct {
+		rx, tx      uint64
+		index       int
+		bootID      string
+		periodStart time.Time
+	}
+	tt := []struct {
+		name    string
+		samples []sample
+		wantRX  uint64
+		wantTX  uint64
+	}{
+		{
+			name:    "first sample is a baseline",
+			samples: []sample{{1000, 500, 2, "a", march}},
+		},
+		{
+			name: "increasing counters",
+			samples: []sample{
+				{1000, 500, 2, "a", march},
+				{1500, 700, 2, "a", march},
+				{2500, 800, 2, "a", march},
+			},
+			wantRX: 1500,
+			wantTX: 300,
+		},
+		{
+			name: "reboot",
+			samples: []sample{
+				{1000, 500, 2, "a", march},
+				{1500, 700, 2, "a", march},
+				{300, 100, 2, "b", march},
+			},
+			wantRX: 800,
+			wantTX: 300,
+		},
+		{
+			name: "reboot with higher counters",
+			samples: []sample{
+				{1000, 500, 2, "a", march},
+				{1500, 700, 2, "a", march},
+				{3000, 1000, 2, "b", march},
+			},
+			wantRX: 3500,
+			wantTX: 1200,
+		},
+		{
+			name: "driver reload",
+			samples: []sample{
+				{1000, 500, 2, "a", march},
+				{1500, 700, 2, "a", march},
+				{100, 50, 2, "a", march},
+			},
+			wantRX: 600,
+			wantTX: 250,
+		},
+		{
+			name: "recreated link",
+			samples: []sample{
+				{1000, 500, 2, "a", march},
+				{1500, 700, 2, "a", march},
+				{2000, 900, 7, "a", march},
+			},
+			wantRX: 2500,
+			wantTX: 1100,
+		},
+		{
+			name: "new billing period",
+			samples: []sample{
+				{1000, 500, 2, "a", march},
+				{1500, 700, 2, "a", march},
+				{1800, 900, 2, "a", april},
+			},
+			wantRX: 300,
+			wantTX: 200,
+		},
+	}
+
+	for _, tc := range tt {
+		counter := &trafficCounter{}
+		for _, s := range tc.samples {
+			counter.update(s.rx, s.tx, s.index, s.bootID, s.periodStart)
+		}
+		if counter.RX != tc.wantRX || counter.TX != tc.wantTX {
+			t.Fatalf("%s: got %d/%d, wanted %d/%d\n", tc.name, counter.RX, counter.TX, tc.wantRX, tc.wantTX)
+		}
+	}
+}
+
+func TestTrafficCounterSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gobar", "traffic", "wlan0.json")
+
+	counter, err := loadTrafficCounter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.update(1000, 500, 2, "a", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local))
+	counter.update(1500, 700, 2, "a", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local))
+	if err := counter.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadTrafficCounter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.RX != counter.RX || loaded.TX != counter.TX || !loaded.PeriodStart.Equal(counter.PeriodStart) {
+		t.Fatalf("got %+v, wanted %+v\n", loaded, counter)
+	}
+}
+
+func TestBillingPeriodStart(t *testing.T) {
+	tt := []struct {
+		name string
+		now  time.Time
+		day  int
+		want time.Time
+	}{
+		{
+			name: "after billing day",
+			now:  time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC),
+			day:  15,
+			want: time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "on billing day",
+			now:  time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC),
+			day:  15,
+			want: time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "before billing day",
+			now:  time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC),
+			day:  15,
+			want: time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "previous year",
+			now:  time.Date(2024, time.January, 10, 12, 0, 0, 0, time.UTC),
+			day:  15,
+			want: time.Date(2023, time.December, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "short month",
+			now:  time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC),
+			day:  31,
+			want: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "end of short month",
+			now:  time.Date(2023, time.February, 28, 12, 0, 0, 0, time.UTC),
+			day:  30,
+			want: time.Date(2023, time.February, 28, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range tt {
+		if got := billingPeriodStart(tc.now, tc.day); !got.Equal(tc.want) {
+			t.Fatalf("%s: got %s, wanted %s\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tt := []struct {
+		input   string
+		want    byteSize
+		wantErr bool
+	}{
+		{input: "1024", want: 1024},
+		{input: "500MB", want: 500e6},
+		{input: "1.5 GiB", want: 1.5 * (1 << 30)},
+		{input: "20GB", want: 20e9},
+		{input: "10 parsecs", wantErr: true},
+		{input: "GiB", wantErr: true},
+	}
+
+	for _, tc := range tt {
+		got, err := parseByteSize(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, wanted error %t\n", tc.input, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got %f, wanted %f\n", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestNetworkAccountingCorruptFile(t *testing.T) {
+	c := col.Color{}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "eth0.json"), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	newIface := func(name string, index int, ip string) iface {
+		return iface{
+			hideIP: true,
+			link:   &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: name, Index: index, OperState: netlink.OperUp}},
+			ipv6:   net.ParseIP(ip),
+		}
+	}
+	n := &Network{
+		Accounting: true,
+		BillingDay: 1,
+		trafficDir: dir,
+		bootID:     "boot",
+		ifaces:     []iface{newIface("eth0", 2, "2001:db8::1"), newIface("eth1", 3, "2001:db8::2")},
+	}
+
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	for i := range n.ifaces {
+		n.accountOrLog(&n.ifaces[i], 1000, 2000, now)
+	}
+
+	if n.ifaces[0].traffic != nil || n.ifaces[0].trafficErr == nil {
+		t.Fatalf("corrupt file: got counter %+v and error %v, wanted an error\n", n.ifaces[0].traffic, n.ifaces[0].trafficErr)
+	}
+	if n.ifaces[1].traffic == nil || n.ifaces[1].trafficErr != nil {
+		t.Fatalf("valid file: got counter %+v and error %v, wanted a counter\n", n.ifaces[1].traffic, n.ifaces[1].trafficErr)
+	}
+
+	tx := make(chan []i3.Block, 1)
+	n.print(tx, nil, c)
+	blocks := <-tx
+	wantTexts := []string{"eth0", "eth1 Σ0B"}
+	if len(blocks) != len(wantTexts) {
+		t.Fatalf("got %d blocks, wanted %d\n", len(blocks), len(wantTexts))
+	}
+	for i, want := range wantTexts {
+		if blocks[i].FullText != want || blocks[i].Color != c.Normal() {
+			t.Fatalf("got %q colored %q, wanted %q colored %q\n", blocks[i].FullText, blocks[i].Color, want, c.Normal())
+		}
+	}
+}
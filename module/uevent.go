@@ -0,0 +1,101 @@
+package module
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// uevent is an event of a kernel object, such as a power supply changing its
+// status.
+type uevent struct {
+	// for example "add", "remove" or "change"
+	action string
+	// the path of the object under /sys
+	devpath string
+	env     map[string]string
+}
+
+// parseUevent parses a kernel uevent, which is a header followed by
+// NUL-separated variables, for example
+// "change@/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC\x00ACTION=change\x00SUBSYSTEM=power_supply\x00...".
+func parseUevent(data []byte) (uevent, bool) {
+	fields := bytes.Split(data, []byte{0})
+	action, devpath, ok := strings.Cut(string(fields[0]), "@")
+	if !ok {
+		return uevent{}, false
+	}
+
+	ev := uevent{action: action, devpath: devpath, env: map[string]string{}}
+	for _, field := range fields[1:] {
+		if key, value, ok := strings.Cut(string(field), "="); ok {
+			ev.env[key] = value
+		}
+	}
+	return ev, true
+}
+
+// subscribeUevents sends the kernel uevents of the subsystem, for example
+// "power_supply", until done is closed, after which the returned channel is
+// closed. If the socket's buffer overflows, an event with the "overflow"
+// action is sent since events were lost.
+func subscribeUevents(subsystem string, done chan struct{}) (chan uevent, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+	// Group 1 receives the events sent by the kernel, as opposed to those
+	// rebroadcast by udev.
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	// Wake up regularly to notice when done is closed.
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: 1}); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	events := make(chan uevent)
+	go func() {
+		defer func() {
+			unix.Close(fd)
+			close(events)
+		}()
+
+		buf := make([]byte, 64*1024)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			var ev uevent
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			switch {
+			case errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR):
+				continue
+			case errors.Is(err, unix.ENOBUFS):
+				ev = uevent{action: "overflow"}
+			case err != nil:
+				return
+			default:
+				var ok bool
+				if ev, ok = parseUevent(buf[:n]); !ok || ev.env["SUBSYSTEM"] != subsystem {
+					continue
+				}
+			}
+
+			select {
+			case events <- ev:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
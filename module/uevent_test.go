@@ -0,0 +1,65 @@
+package module
+
+import (
+	"testing"
+)
+
+func TestParseUevent(t *testing.T) {
+	tt := []struct {
+		name        string
+		data        string
+		wantOK      bool
+		wantAction  string
+		wantDevpath string
+		wantEnv     map[string]string
+	}{
+		{
+			name:        "power supply change",
+			data:        "change@/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC\x00ACTION=change\x00DEVPATH=/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC\x00SUBSYSTEM=power_supply\x00POWER_SUPPLY_NAME=AC\x00POWER_SUPPLY_ONLINE=1\x00SEQNUM=4321\x00",
+			wantOK:      true,
+			wantAction:  "change",
+			wantDevpath: "/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC",
+			wantEnv: map[string]string{
+				"ACTION":              "change",
+				"DEVPATH":             "/devices/LNXSYSTM:00/ACPI0003:00/power_supply/AC",
+				"SUBSYSTEM":           "power_supply",
+				"POWER_SUPPLY_NAME":   "AC",
+				"POWER_SUPPLY_ONLINE": "1",
+				"SEQNUM":              "4321",
+			},
+		},
+		{
+			name:        "no variables",
+			data:        "remove@/devices/platform/usb",
+			wantOK:      true,
+			wantAction:  "remove",
+			wantDevpath: "/devices/platform/usb",
+			wantEnv:     map[string]string{},
+		},
+		{
+			name: "udev message",
+			data: "libudev\x00\xfe\xed\xca\xfe",
+		},
+	}
+
+	for _, tc := range tt {
+		ev, ok := parseUevent([]byte(tc.data))
+		if ok != tc.wantOK {
+			t.Fatalf("%s: got ok %t, wanted %t\n", tc.name, ok, tc.wantOK)
+		}
+		if !ok {
+			continue
+		}
+		if ev.action != tc.wantAction || ev.devpath != tc.wantDevpath {
+			t.Fatalf("%s: got %s@%s, wanted %s@%s\n", tc.name, ev.action, ev.devpath, tc.wantAction, tc.wantDevpath)
+		}
+		if len(ev.env) != len(tc.wantEnv) {
+			t.Fatalf("%s: got %v, wanted %v\n", tc.name, ev.env, tc.wantEnv)
+		}
+		for key, value := range tc.wantEnv {
+			if ev.env[key] != value {
+				t.Fatalf("%s: got %s=%q, wanted %q\n", tc.name, key, ev.env[key], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package module
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// errNotWireless is returned by a wirelessSource for links that aren't
+// wireless.
+var errNotWireless = errors.New("not a wireless link")
+
+// wirelessInfo holds the details of a wireless link's connection.
+type wirelessInfo struct {
+	connected bool
+	// empty if the source doesn't know it
+	ssid string
+	// signal strength in dBm and as a percentage
+	signal  int
+	quality int
+	// frequency in MHz and tx bitrate in Mbit/s, zero if unknown
+	frequency int
+	bitrate   float64
+}
+
+// band returns the frequency band of the connection, for example "5GHz".
+func (w wirelessInfo) band() string {
+	switch {
+	case w.frequency >= 2400 && w.frequency < 2500:
+		return "2.4GHz"
+	case w.frequency >= 5150 && w.frequency < 5925:
+		return "5GHz"
+	case w.frequency >= 5925 && w.frequency < 7125:
+		return "6GHz"
+	case w.frequency >= 57000 && w.frequency < 71000:
+		return "60GHz"
+	default:
+		return ""
+	}
+}
+
+// signalQuality converts a signal strength in dBm to a percentage, treating
+// -100dBm and below as 0% and -50dBm and above as 100%.
+func signalQuality(dbm int) int {
+	quality := 2 * (dbm + 100)
+	if quality < 0 {
+		return 0
+	}
+	if quality > 100 {
+		return 100
+	}
+	return quality
+}
+
+// wirelessSource provides the details of wireless links.
+type wirelessSource interface {
+	// wireless returns the details of the link's connection, or
+	// errNotWireless if the link isn't wireless.
+	wireless(link netlink.Link) (wirelessInfo, error)
+	close()
+}
+
+// newWirelessSource returns a source querying nl80211 in the namespace,
+// falling back to /proc/net/wireless if nl80211 isn't available. The latter
+// only has the links of the bar's own namespace.
+func newWirelessSource(ns netns.NsHandle) (wirelessSource, error) {
+	source, err := newNL80211Source(ns)
+	if err == nil {
+		return source, nil
+	}
+	if ns.IsOpen() {
+		return nil, err
+	}
+	return &procWirelessSource{path: "/proc/net/wireless"}, nil
+}
+
+// nl80211Source queries wireless links over generic netlink.
+type nl80211Source struct {
+	sock   *nl.NetlinkSocket
+	family uint16
+}
+
+func newNL80211Source(ns netns.NsHandle) (*nl80211Source, error) {
+	sock, err := nl.GetNetlinkSocketAt(ns, netns.None(), unix.NETLINK_GENERIC)
+	if err != nil {
+		return nil, err
+	}
+	// Don't block the module if the kernel never replies.
+	if err := sock.SetReceiveTimeout(&unix.Timeval{Sec: 1}); err != nil {
+		sock.Close()
+		return nil, err
+	}
+
+	s := &nl80211Source{sock: sock}
+	msgs, err := s.request(nl.GENL_ID_CTRL, nl.GENL_CTRL_CMD_GETFAMILY, 0,
+		nl.NewRtAttr(nl.GENL_CTRL_ATTR_FAMILY_NAME, nl.ZeroTerminated("nl80211")))
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+	for _, msg := range msgs {
+		attrs, err := nl.ParseRouteAttr(msg)
+		if err != nil {
+			sock.Close()
+			return nil, err
+		}
+		for _, attr := range attrs {
+			if attr.Attr.Type == nl.GENL_CTRL_ATTR_FAMILY_ID && len(attr.Value) >= 2 {
+				s.family = nl.NativeEndian().Uint16(attr.Value)
+			}
+		}
+	}
+	if s.family == 0 {
+		sock.Close()
+		return nil, errors.New("nl80211 not available")
+	}
+
+	return s, nil
+}
+
+// request sends a generic netlink request and returns the attributes of each
+// message of the reply.
+func (s *nl80211Source) request(family uint16, cmd uint8, flags int, attrs ...*nl.RtAttr) ([][]byte, error) {
+	req := nl.NewNetlinkRequest(int(family), flags|unix.NLM_F_ACK)
+	req.AddData(&nl.Genlmsg{Command: cmd, Version: 1})
+	for _, attr := range attrs {
+		req.AddData(attr)
+	}
+	if err := s.sock.Send(req); err != nil {
+		return nil, err
+	}
+
+	replies := [][]byte{}
+	for {
+		msgs, _, err := s.sock.Receive()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != req.Seq {
+				continue
+			}
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return replies, nil
+			case unix.NLMSG_ERROR:
+				if errno := int32(nl.NativeEndian().Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				return replies, nil
+			}
+			if len(m.Data) >= nl.SizeofGenlmsg {
+				replies = append(replies, m.Data[nl.SizeofGenlmsg:])
+			}
+		}
+	}
+}
+
+func (s *nl80211Source) wireless(link netlink.Link) (wirelessInfo, error) {
+	info := wirelessInfo{}
+	ifindex := nl.NewRtAttr(unix.NL80211_ATTR_IFINDEX, nl.Uint32Attr(uint32(link.Attrs().Index)))
+
+	msgs, err := s.request(s.family, unix.NL80211_CMD_GET_INTERFACE, 0, ifindex)
+	if errors.Is(err, unix.ENODEV) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EINVAL) {
+		return info, errNotWireless
+	} else if err != nil {
+		return info, err
+	}
+	for _, msg := range msgs {
+		attrs, err := nl.ParseRouteAttr(msg)
+		if err != nil {
+			return info, err
+		}
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case unix.NL80211_ATTR_SSID:
+				info.ssid = string(attr.Value)
+			case unix.NL80211_ATTR_WIPHY_FREQ:
+				if len(attr.Value) >= 4 {
+					info.frequency = int(nl.NativeEndian().Uint32(attr.Value))
+				}
+			}
+		}
+	}
+	if info.ssid == "" {
+		return info, nil
+	}
+	info.connected = true
+
+	// The station of a connected link in managed mode is its access point.
+	msgs, err = s.request(s.family, unix.NL80211_CMD_GET_STATION, unix.NLM_F_DUMP, ifindex)
+	if err != nil {
+		return info, err
+	}
+	for _, msg := range msgs {
+		attrs, err := nl.ParseRouteAttr(msg)
+		if err != nil {
+			return info, err
+		}
+		for _, attr := range attrs {
+			if attr.Attr.Type == unix.NL80211_ATTR_STA_INFO {
+				if err := info.parseStationInfo(attr.Value); err != nil {
+					return info, err
+				}
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// parseStationInfo reads the signal strength and tx bitrate from the nested
+// NL80211_ATTR_STA_INFO attribute.
+func (w *wirelessInfo) parseStationInfo(data []byte) error {
+	attrs, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return err
+	}
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case unix.NL80211_STA_INFO_SIGNAL:
+			if len(attr.Value) >= 1 {
+				w.signal = int(int8(attr.Value[0]))
+				w.quality = signalQuality(w.signal)
+			}
+		case unix.NL80211_STA_INFO_TX_BITRATE:
+			rates, err := nl.ParseRouteAttr(attr.Value)
+			if err != nil {
+				return err
+			}
+			// Both are in units of 100kbit/s, the 32-bit one is preferred
+			// since the 16-bit one overflows for fast links.
+			for _, rate := range rates {
+				switch {
+				case rate.Attr.Type == unix.NL80211_RATE_INFO_BITRATE32 && len(rate.Value) >= 4:
+					w.bitrate = float64(nl.NativeEndian().Uint32(rate.Value)) / 10
+				case rate.Attr.Type == unix.NL80211_RATE_INFO_BITRATE && len(rate.Value) >= 2 && w.bitrate == 0:
+					w.bitrate = float64(nl.NativeEndian().Uint16(rate.Value)) / 10
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *nl80211Source) close() {
+	s.sock.Close()
+}
+
+// procWirelessSource reads the signal strength of wireless links from
+// /proc/net/wireless, which has no SSID, frequency or bitrate.
+type procWirelessSource struct {
+	path string
+}
+
+func (s *procWirelessSource) wireless(link netlink.Link) (wirelessInfo, error) {
+	data, err := os.ReadFile(s.path)
+	// The file only exists while there are wireless links.
+	if errors.Is(err, os.ErrNotExist) {
+		return wirelessInfo{}, errNotWireless
+	} else if err != nil {
+		return wirelessInfo{}, err
+	}
+	stats, err := parseProcWireless(data)
+	if err != nil {
+		return wirelessInfo{}, err
+	}
+	info, ok := stats[link.Attrs().Name]
+	if !ok {
+		return info, errNotWireless
+	}
+	return info, nil
+}
+
+func (s *procWirelessSource) close() {}
+
+// parseProcWireless returns the signal strength of each wireless link from
+// the contents of /proc/net/wireless.
+func parseProcWireless(data []byte) (map[string]wirelessInfo, error) {
+	stats := map[string]wirelessInfo{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// wlp2s0: 0000   54.  -56.  -256        0      0      0      0    118        0
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 3 {
+			continue
+		}
+
+		link, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "."), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link quality %q", fields[1])
+		}
+		level, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signal level %q", fields[2])
+		}
+		// Some drivers report the level as an unsigned byte.
+		if level > 0 {
+			level -= 256
+		}
+
+		// The link quality is out of 70 for most drivers.
+		quality := int(link / 70 * 100)
+		if quality > 100 {
+			quality = 100
+		}
+		// Disconnected links report no signal.
+		stats[strings.TrimSpace(name)] = wirelessInfo{
+			connected: level != 0,
+			signal:    int(level),
+			quality:   quality,
+		}
+	}
+
+	return stats, scanner.Err()
+}
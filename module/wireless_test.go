@@ -0,0 +1,193 @@
+package module
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	col "github.com/jmbaur/gobar/color"
+	"github.com/jmbaur/gobar/i3"
+	"github.com/vishvananda/netlink"
+)
+
+type fakeWirelessSource map[string]wirelessInfo
+
+func (f fakeWirelessSource) wireless(link netlink.Link) (wirelessInfo, error) {
+	info, ok := f[link.Attrs().Name]
+	if !ok {
+		return info, errNotWireless
+	}
+	return info, nil
+}
+
+func (f fakeWirelessSource) close() {}
+
+func TestNetworkWireless(t *testing.T) {
+	c := col.Color{}
+	tt := []struct {
+		name      string
+		info      *wirelessInfo
+		detailed  bool
+		wantText  string
+		wantColor string
+	}{
+		{
+			name:      "wired",
+			wantText:  "wlan0",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "disconnected",
+			info:      &wirelessInfo{},
+			wantText:  "wlan0",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "strong signal",
+			info:      &wirelessInfo{connected: true, ssid: "home", signal: -55, quality: 90, frequency: 5180, bitrate: 866.7},
+			wantText:  "wlan0 home █90%",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "detailed",
+			info:      &wirelessInfo{connected: true, ssid: "home", signal: -55, quality: 90, frequency: 5180, bitrate: 866.7},
+			detailed:  true,
+			wantText:  "wlan0 home █90% 5GHz -55dBm 866.7Mbit/s",
+			wantColor: c.Normal(),
+		},
+		{
+			name:      "weak signal",
+			info:      &wirelessInfo{connected: true, ssid: "cafe", signal: -75, quality: 50, frequency: 2437},
+			wantText:  "wlan0 cafe ▄50%",
+			wantColor: c.Yellow(),
+		},
+		{
+			name:      "very weak signal",
+			info:      &wirelessInfo{connected: true, ssid: "cafe", signal: -85, quality: 30, frequency: 2437},
+			wantText:  "wlan0 cafe ▃30%",
+			wantColor: c.Red(),
+		},
+		{
+			name:      "without ssid",
+			info:      &wirelessInfo{connected: true, signal: -60, quality: 80},
+			wantText:  "wlan0 ▇80%",
+			wantColor: c.Normal(),
+		},
+	}
+
+	for _, tc := range tt {
+		source := fakeWirelessSource{}
+		if tc.info != nil {
+			source["wlan0"] = *tc.info
+		}
+		link := &netlink.Device{LinkAttrs: netlink.LinkAttrs{
+			Name:      "wlan0",
+			Index:     3,
+			OperState: netlink.OperUp,
+		}}
+		n := &Network{
+			Wireless:       true,
+			SignalWarning:  -70,
+			SignalCritical: -80,
+			wireless:       source,
+			ifaces: []iface{{
+				hideIP:   true,
+				detailed: tc.detailed,
+				link:     link,
+				ipv6:     net.ParseIP("2001:db8::1"),
+			}},
+		}
+
+		n.sampleWireless()
+
+		tx := make(chan []i3.Block, 1)
+		n.print(tx, nil, c)
+		blocks := <-tx
+		if len(blocks) != 1 {
+			t.Fatalf("%s: got %d blocks, wanted 1\n", tc.name, len(blocks))
+		}
+		if blocks[0].FullText != tc.wantText {
+			t.Fatalf("%s: got %q, wanted %q\n", tc.name, blocks[0].FullText, tc.wantText)
+		}
+		if blocks[0].Color != tc.wantColor {
+			t.Fatalf("%s: got color %q, wanted %q\n", tc.name, blocks[0].Color, tc.wantColor)
+		}
+	}
+}
+
+// failingWirelessSource fails to report the connection of every link.
+type failingWirelessSource struct{}
+
+func (failingWirelessSource) wireless(netlink.Link) (wirelessInfo, error) {
+	return wirelessInfo{}, errors.New("device or resource busy")
+}
+
+func (failingWirelessSource) close() {}
+
+func TestNetworkWirelessError(t *testing.T) {
+	c := col.Color{}
+	last := &wirelessInfo{connected: true, ssid: "home", signal: -55, quality: 90}
+	n := &Network{
+		Wireless:       true,
+		SignalWarning:  -70,
+		SignalCritical: -80,
+		wireless:       failingWirelessSource{},
+		ifaces: []iface{
+			{
+				hideIP:   true,
+				link:     &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "wlan0", Index: 3, OperState: netlink.OperUp}},
+				ipv6:     net.ParseIP("2001:db8::1"),
+				wireless: last,
+			},
+			{
+				hideIP: true,
+				link:   &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2, OperState: netlink.OperUp}},
+				ipv6:   net.ParseIP("2001:db8::2"),
+			},
+		},
+	}
+
+	n.sampleWireless()
+
+	tx := make(chan []i3.Block, 1)
+	n.print(tx, nil, c)
+	blocks := <-tx
+	wantTexts := []string{"wlan0 home █90%", "eth0"}
+	if len(blocks) != len(wantTexts) {
+		t.Fatalf("got %d blocks, wanted %d\n", len(blocks), len(wantTexts))
+	}
+	for i, want := range wantTexts {
+		if blocks[i].FullText != want || blocks[i].Color != c.Normal() {
+			t.Fatalf("got %q colored %q, wanted %q colored %q\n", blocks[i].FullText, blocks[i].Color, want, c.Normal())
+		}
+	}
+}
+
+func TestParseProcWireless(t *testing.T) {
+	data := []byte(`Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+ face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+wlp2s0: 0000   54.  -56.  -256        0      0      0      0    118        0
+ wlan1: 0000   35.  200.  -256        0      0      0      0      0        0
+`)
+
+	stats, err := parseProcWireless(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name string
+		want wirelessInfo
+	}{
+		{name: "wlp2s0", want: wirelessInfo{connected: true, signal: -56, quality: 77}},
+		{name: "wlan1", want: wirelessInfo{connected: true, signal: -56, quality: 50}},
+	}
+	for _, tc := range tt {
+		if got := stats[tc.name]; got != tc.want {
+			t.Fatalf("%s: got %+v, wanted %+v\n", tc.name, got, tc.want)
+		}
+	}
+	if len(stats) != len(tt) {
+		t.Fatalf("got %d links, wanted %d\n", len(stats), len(tt))
+	}
+}
@@ -0,0 +1,37 @@
+package width
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tt := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "empty", s: "", want: 0},
+		{name: "ascii", s: "MEM: 42%", want: 8},
+		{name: "latin accents", s: "Zürich", want: 6},
+		{name: "combining mark", s: "Zu\u0308rich", want: 6},
+		{name: "cjk", s: "東京", want: 4},
+		{name: "hangul", s: "서울", want: 4},
+		{name: "fullwidth digits", s: "１２", want: 4},
+		{name: "emoji", s: "\U0001f50b 80%", want: 6},
+		{name: "emoji presentation selector", s: "\u26a0\ufe0f", want: 2},
+		{name: "zwj sequence", s: "\U0001f469\u200d\U0001f4bb", want: 2},
+		{name: "control characters", s: "a\tb\n", want: 2},
+	}
+
+	for _, tc := range tt {
+		got := String(tc.s)
+		if got != tc.want {
+			t.Fatalf("%s: got %d, wanted %d\n", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMarkup(t *testing.T) {
+	got := Markup("<span foreground=\"red\">東京</span> &amp; UTC")
+	if want := 10; got != want {
+		t.Fatalf("got %d, wanted %d\n", got, want)
+	}
+}